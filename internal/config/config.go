@@ -0,0 +1,202 @@
+// Package config reads and writes the files the tools keep in the per-user
+// config directory, or wherever SetPath moved them.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Dir returns the per-user directory holding credentials and caches,
+// creating it if needed.
+func Dir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, "routeros-tools")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// paths holds the files moved out of the config directory with SetPath
+var paths = make(map[string]string)
+
+// SetPath keeps name at path instead of in the config directory, e.g. for
+// -cache-file. An empty path restores the default.
+func SetPath(name, path string) {
+	if path == "" {
+		delete(paths, name)
+		return
+	}
+	paths[name] = path
+}
+
+// ReadFile reads name from the config directory, falling back to the
+// working directory where older versions wrote it.
+func ReadFile(name string) ([]byte, error) {
+	if path, ok := paths[name]; ok {
+		return os.ReadFile(path)
+	}
+	if dir, err := Dir(); err == nil {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			return data, err
+		}
+	}
+	return os.ReadFile(name)
+}
+
+// ErrReadOnly is returned by WriteFile for a file moved with SetPath that
+// exists but can't be opened for writing
+var ErrReadOnly = errors.New("file is read-only")
+
+// WriteFile writes name to the config directory. A file moved with SetPath
+// is left alone if it is read-only, so a shared copy is never replaced.
+func WriteFile(name string, data []byte) error {
+	if path, ok := paths[name]; ok {
+		if f, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+			f.Close()
+		} else if errors.Is(err, os.ErrPermission) || errors.Is(err, syscall.EROFS) {
+			return fmt.Errorf("%s: %w", path, ErrReadOnly)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return WriteFileAtomic(path, data, 0600)
+	}
+	dir, err := Dir()
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(dir, name), data, 0600)
+}
+
+// Lock files older than staleLockAge are assumed to be left by a crashed
+// run. Updates only read and write a small file, so a live lock is never
+// held that long, and the age is below lockTimeout so a waiting run gets to
+// break a stale lock rather than giving up.
+const (
+	lockTimeout  = 5 * time.Second
+	staleLockAge = 2 * time.Second
+)
+
+// Lock takes an advisory lock on name with a lock file next to it,
+// so concurrent runs don't lose each other's updates in a read-modify-write
+// cycle. It waits up to lockTimeout for another run to release it; release
+// the lock by calling the returned function.
+func Lock(name string) (func(), error) {
+	path, moved := paths[name]
+	if !moved {
+		dir, err := Dir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, name)
+	}
+	path += ".lock"
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			// The pid and time tell this lock apart from later ones
+			token := fmt.Sprintf("%d %d\n", os.Getpid(), time.Now().UnixNano())
+			f.WriteString(token)
+			f.Close()
+			return func() { removeLock(path, token) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			if stale, err := os.ReadFile(path); err == nil && breakLock(path, string(stale)) {
+				continue
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s", path)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// breakLock removes the stale lock file holding stale. It is renamed aside
+// first, which only one run can do, and checked to still hold stale, so a
+// lock another run took since it was read is handed back rather than
+// deleted.
+func breakLock(path, stale string) bool {
+	aside := fmt.Sprintf("%s.stale%d", path, os.Getpid())
+	if err := os.Rename(path, aside); err != nil {
+		return false
+	}
+	defer os.Remove(aside)
+	if data, err := os.ReadFile(aside); err != nil || string(data) != stale {
+		os.Link(aside, path) // fails harmlessly if the lock was retaken meanwhile
+		return false
+	}
+	slog.Debug("removed stale lock file", "path", path)
+	return true
+}
+
+// removeLock releases the lock holding token, unless it was broken as stale
+// and the file now belongs to another run.
+func removeLock(path, token string) {
+	if data, err := os.ReadFile(path); err == nil && string(data) != token {
+		return
+	}
+	os.Remove(path)
+}
+
+// Update runs update between taking and releasing the lock on name. If the
+// lock can't be taken in time the update is skipped and the error returned,
+// since running it unlocked could overwrite another run's changes. Where no
+// lock file can be created at all, e.g. next to a read-only shared file,
+// there is no writer to race with and the update runs anyway.
+func Update(name string, update func()) error {
+	unlock, err := Lock(name)
+	switch {
+	case err == nil:
+		defer unlock()
+	case errors.Is(err, os.ErrPermission) || errors.Is(err, syscall.EROFS):
+		slog.Debug("config file can't be locked, updating unlocked", "name", name, "error", err)
+	default:
+		return err
+	}
+	update()
+	return nil
+}
+
+// WriteFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never see a partly written file even if we
+// crash or another run writes at the same time.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
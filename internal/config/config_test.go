@@ -0,0 +1,90 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// lockPath moves name into a temporary directory and returns its lock file
+func lockPath(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	SetPath(name, path)
+	t.Cleanup(func() { SetPath(name, "") })
+	return path + ".lock"
+}
+
+func TestUpdateSkipsWhenLocked(t *testing.T) {
+	lock := lockPath(t, "seen.json")
+	if err := os.WriteFile(lock, []byte("1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Keep the lock fresh so it is never taken for a stale one
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(100 * time.Millisecond):
+				now := time.Now()
+				os.Chtimes(lock, now, now)
+			}
+		}
+	}()
+
+	ran := false
+	if err := Update("seen.json", func() { ran = true }); err == nil {
+		t.Error("Update succeeded while another run held the lock")
+	}
+	if ran {
+		t.Error("update ran without the lock")
+	}
+}
+
+func TestLockBreaksStaleLock(t *testing.T) {
+	lock := lockPath(t, "seen.json")
+	if err := os.WriteFile(lock, []byte("1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	if err := os.Chtimes(lock, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := Lock("seen.json")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if info, err := os.Stat(lock); err != nil || time.Since(info.ModTime()) > staleLockAge {
+		t.Errorf("lock file wasn't retaken: %v %v", info, err)
+	}
+	unlock()
+	if _, err := os.Stat(lock); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind after unlock: %v", err)
+	}
+	if matches, _ := filepath.Glob(lock + ".stale*"); len(matches) > 0 {
+		t.Errorf("stale lock left aside: %v", matches)
+	}
+}
+
+func TestUnlockKeepsOtherRunsLock(t *testing.T) {
+	lock := lockPath(t, "seen.json")
+	unlock, err := Lock("seen.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another run broke our lock as stale and took its own
+	os.Remove(lock)
+	if err := os.WriteFile(lock, []byte("2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+	if data, err := os.ReadFile(lock); err != nil || string(data) != "2\n" {
+		t.Errorf("unlock removed another run's lock: %q %v", data, err)
+	}
+}
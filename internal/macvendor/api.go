@@ -0,0 +1,161 @@
+package macvendor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type vendorResponse struct {
+	VendorDetails struct {
+		Company string `json:"company"`
+	} `json:"vendorDetails"`
+}
+
+func queryMacVendorAPI(ctx context.Context, oui string) string {
+	backoff := initialBackoff
+	maxRetries := 3
+
+	for retry := 0; retry < maxRetries; retry++ {
+		if vendorAPIDisabled.Load() {
+			return "Unknown"
+		}
+		url := fmt.Sprintf(APIURL, oui)
+		client := &http.Client{Timeout: Timeout}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return "Unknown"
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			slog.Debug("vendor API request failed", "oui", oui, "err", err)
+			if ctx.Err() == nil {
+				recordVendorAPIFailure()
+			}
+			return "Unknown"
+		}
+		defer resp.Body.Close()
+		slog.Debug("vendor API response", "oui", oui, "status", resp.StatusCode)
+
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			recordVendorAPIFailure()
+		} else {
+			vendorAPIFailures.Store(0)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if retry < maxRetries-1 { // Don't sleep on last retry
+				// Prefer the server's Retry-After over our own guess
+				wait := backoff
+				if d, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+					wait = min(d, maxBackoff)
+				}
+				ReportStatus(fmt.Sprintf("Rate limit reached, waiting %v before retry...", wait))
+				if !sleep(ctx, wait) {
+					return "Unknown"
+				}
+				backoff *= 2 // Exponential backoff
+				if backoff > maxBackoff {
+					backoff = maxBackoff
+				}
+				continue
+			}
+			return "Rate Limited"
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return "Unknown"
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "Unknown"
+		}
+
+		return parseVendorResponse(body)
+	}
+
+	return "Rate Limited"
+}
+
+// vendorAPIFailures counts consecutive vendor API requests that failed or
+// were rate limited, and any other response resets it. Reaching
+// vendorAPIFailureLimit sets vendorAPIDisabled for the rest of the run.
+var (
+	vendorAPIFailures atomic.Int32
+	vendorAPIDisabled atomic.Bool
+)
+
+// recordVendorAPIFailure counts a failed vendor API request, disabling the
+// API once the limit is reached.
+func recordVendorAPIFailure() {
+	if vendorAPIFailures.Add(1) >= vendorAPIFailureLimit && !vendorAPIDisabled.Swap(true) {
+		ReportStatus(fmt.Sprintf("Vendor API failed %d times in a row, skipping it for the rest of this run", vendorAPIFailureLimit))
+	}
+}
+
+// parseRetryAfter interprets a Retry-After header given either as delay
+// seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// parseVendorResponse extracts the company name from a vendor API response.
+// Plain text bodies are the name itself; JSON bodies may nest it under
+// vendorDetails or use one of the common top-level field names.
+func parseVendorResponse(body []byte) string {
+	var vendor vendorResponse
+	if err := json.Unmarshal(body, &vendor); err != nil {
+		return strings.TrimSpace(string(body)) // Return plain text if not JSON
+	}
+	if vendor.VendorDetails.Company != "" {
+		return vendor.VendorDetails.Company
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(body, &fields); err == nil {
+		for _, key := range []string{"company", "vendor", "vendorName", "organization"} {
+			if name, ok := fields[key].(string); ok && name != "" {
+				return name
+			}
+		}
+	}
+	return "Unknown"
+}
+
+// sleep waits between rate-limited retries; tests replace it to skip the
+// backoff
+var sleep = sleepContext
+
+// sleepContext waits for d, returning false early if ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,193 @@
+package macvendor
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ezeql/routeros-misc-tools/internal/config"
+)
+
+// Cache is the vendor_cache.json file, keyed by OUI
+type Cache struct {
+	Vendors map[string]CacheEntry `json:"vendors"`
+}
+
+// CacheEntry is a resolved vendor and when it was looked up
+type CacheEntry struct {
+	Vendor    string    `json:"vendor"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// vendorCache is the vendor cache file as loaded at the start of a lookup
+// pass, and vendorCacheNew the entries resolved since, which
+// flushVendorCache writes back. Both are guarded by vendorCacheMu.
+var (
+	vendorCacheMu  sync.Mutex
+	vendorCache    = Cache{Vendors: make(map[string]CacheEntry)}
+	vendorCacheNew = make(map[string]CacheEntry)
+)
+
+// vendorCacheLockReported is set once a flush has been skipped for a lock
+// timeout, so the message isn't repeated on every flush
+var vendorCacheLockReported atomic.Bool
+
+// LoadCache reads the vendor cache, returning an empty one if it is missing
+// or unreadable.
+func LoadCache() Cache {
+	var cache Cache
+	data, err := config.ReadFile("vendor_cache.json")
+	if err != nil {
+		return Cache{Vendors: make(map[string]CacheEntry)}
+	}
+	if err := json.Unmarshal(data, &cache); err != nil || cache.Vendors == nil {
+		return Cache{Vendors: make(map[string]CacheEntry)}
+	}
+	return cache
+}
+
+// SaveCache writes the vendor cache
+func SaveCache(cache Cache) error {
+	data, err := json.MarshalIndent(cache, "", "    ")
+	if err != nil {
+		return err
+	}
+	return config.WriteFile("vendor_cache.json", data)
+}
+
+// ExportCache writes the vendor cache to path, or stdout for "-", in
+// the vendor_cache.json format and returns the number of entries.
+func ExportCache(path string) (int, error) {
+	cache := LoadCache()
+	data, err := json.MarshalIndent(cache, "", "    ")
+	if err != nil {
+		return 0, err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = os.WriteFile(path, data, 0644)
+	}
+	return len(cache.Vendors), err
+}
+
+// ImportCache merges a cache written by ExportCache into the
+// local one. Entries for OUIs not cached yet are added, and cached ones are
+// replaced only by a newer lookup.
+func ImportCache(path string) (added, updated int, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var imported Cache
+	if err := json.Unmarshal(data, &imported); err != nil {
+		return 0, 0, fmt.Errorf("%s: %v", path, err)
+	}
+
+	lockErr := config.Update("vendor_cache.json", func() {
+		cache := LoadCache()
+		for oui, entry := range imported.Vendors {
+			oui = strings.ToUpper(oui)
+			if _, err := hex.DecodeString(oui); err != nil || len(oui) != 6 || entry.Vendor == "" || entry.Vendor == "Unknown" || entry.Vendor == "Rate Limited" {
+				slog.Debug("skipping imported vendor cache entry", "oui", oui, "vendor", entry.Vendor)
+				continue
+			}
+			current, exists := cache.Vendors[oui]
+			switch {
+			case !exists:
+				added++
+			case entry.Timestamp.After(current.Timestamp):
+				updated++
+			default:
+				continue
+			}
+			cache.Vendors[oui] = entry
+		}
+		if added+updated > 0 {
+			err = SaveCache(cache)
+		}
+	})
+	if lockErr != nil {
+		return 0, 0, lockErr
+	}
+	return added, updated, err
+}
+
+// resolveMacVendor looks up an OUI in the disk cache, then the vendor API
+func resolveMacVendor(ctx context.Context, oui string) string {
+	vendorCacheMu.Lock()
+	entry, exists := vendorCache.Vendors[oui]
+	vendorCacheMu.Unlock()
+
+	// Check cache first
+	if exists {
+		// Cache entry valid for 30 days
+		if time.Since(entry.Timestamp) < TTL {
+			slog.Debug("vendor cache hit", "oui", oui)
+			return entry.Vendor
+		}
+		slog.Debug("vendor cache entry expired", "oui", oui, "cached", entry.Timestamp)
+	} else {
+		slog.Debug("vendor cache miss", "oui", oui)
+	}
+
+	// If not in cache or expired, query API
+	vendor := queryMacVendorAPI(ctx, oui)
+
+	// Only cache if we got a valid vendor response
+	if vendor != "Unknown" && vendor != "Rate Limited" {
+		entry := CacheEntry{Vendor: vendor, Timestamp: time.Now()}
+		vendorCacheMu.Lock()
+		vendorCache.Vendors[oui] = entry
+		vendorCacheNew[oui] = entry
+		vendorCacheMu.Unlock()
+	}
+
+	return vendor
+}
+
+// flushVendorCache writes the entries resolved since the last flush to the
+// vendor cache file in one go. The file is reloaded first so entries saved
+// by other runs meanwhile aren't lost.
+func flushVendorCache() {
+	vendorCacheMu.Lock()
+	defer vendorCacheMu.Unlock()
+	if len(vendorCacheNew) == 0 {
+		return
+	}
+
+	err := config.Update("vendor_cache.json", func() {
+		cache := LoadCache()
+		maps.Copy(cache.Vendors, vendorCacheNew)
+		if err := SaveCache(cache); errors.Is(err, config.ErrReadOnly) {
+			// A shared read-only cache is only read; new vendors are kept
+			// for this run
+			slog.Debug("vendor cache is read-only, not saving new entries", "error", err)
+			clear(vendorCacheNew)
+			return
+		} else if err != nil {
+			ReportStatus(fmt.Sprintf("Warning: Failed to save vendor cache: %v", err))
+			return
+		}
+		vendorCache = cache
+		clear(vendorCacheNew)
+	})
+	if err != nil {
+		// Another run holding the lock is expected now and then; the new
+		// entries are kept and written by the next flush
+		slog.Debug("failed to lock vendor cache", "error", err)
+		if !vendorCacheLockReported.Swap(true) {
+			ReportStatus(fmt.Sprintf("Vendor cache is busy, new vendors will be saved later: %v", err))
+		}
+	}
+}
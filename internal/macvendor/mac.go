@@ -0,0 +1,37 @@
+package macvendor
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NormalizeMAC returns a 48-bit MAC address in RouterOS's uppercase colon
+// form, accepting colon, dash, dotted (Cisco) or bare hex notation.
+func NormalizeMAC(mac string) (string, error) {
+	hexMAC := strings.ToUpper(strings.NewReplacer(":", "", "-", "", ".", "").Replace(strings.TrimSpace(mac)))
+	b, err := hex.DecodeString(hexMAC)
+	if err != nil || len(b) != 6 {
+		return "", fmt.Errorf("invalid MAC address %q", mac)
+	}
+	return strings.ToUpper(net.HardwareAddr(b).String()), nil
+}
+
+// MACKey returns mac normalized for use as a map key, falling back to the
+// uppercased input for malformed addresses so they still compare equal.
+func MACKey(mac string) string {
+	if normalized, err := NormalizeMAC(mac); err == nil {
+		return normalized
+	}
+	return strings.ToUpper(mac)
+}
+
+// OUI returns the first 3 octets of a MAC address as uppercase hex.
+func OUI(mac string) (string, error) {
+	normalized, err := NormalizeMAC(mac)
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(normalized, ":", "")[:6], nil
+}
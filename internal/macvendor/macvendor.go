@@ -0,0 +1,268 @@
+// Package macvendor resolves MAC addresses to hardware vendors: from user
+// overrides, a local OUI database, the vendor cache or an online API, in
+// that order.
+package macvendor
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ezeql/routeros-misc-tools/internal/config"
+)
+
+const (
+	DefaultAPIURL  = "https://api.macvendors.com/%s"
+	DefaultTTL     = 30 * 24 * time.Hour
+	DefaultTimeout = 5 * time.Second
+
+	initialBackoff = 2 * time.Second
+	maxBackoff     = 60 * time.Second
+
+	// Consecutive failed or rate-limited vendor API requests after which
+	// the API isn't called again for the rest of the run
+	vendorAPIFailureLimit = 5
+
+	// Concurrent vendor lookups; kept low so the API rate limit isn't hit instantly
+	vendorLookupWorkers = 4
+)
+
+// Settings for lookups, set from the command line before the first one
+var (
+	// APIURL is the vendor API URL template; %s is replaced with the OUI
+	APIURL = DefaultAPIURL
+	// TTL is how long cached vendor lookups are reused
+	TTL = DefaultTTL
+	// Timeout bounds each vendor API request
+	Timeout = DefaultTimeout
+)
+
+// ReportStatus surfaces events worth telling the user about, such as the
+// vendor API being given up on.
+var ReportStatus = func(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+}
+
+// ReportProgress is called as the lookups of a Resolve call complete
+var ReportProgress = func(done, total int) {}
+
+// OUIDatabase maps 6-hex-digit OUI prefixes to vendor names from the local
+// database, if one was loaded.
+var OUIDatabase map[string]string
+
+// vendorLookup is a single per-run vendor resolution that concurrent callers
+// for the same OUI wait on
+type vendorLookup struct {
+	done   chan struct{}
+	vendor string
+}
+
+var (
+	vendorLookupsMu sync.Mutex
+	vendorLookups   = make(map[string]*vendorLookup)
+)
+
+// Resolve looks up the vendor of every valid MAC address, returning a map
+// keyed by OUI. Invalid MACs are skipped.
+func Resolve(ctx context.Context, macs []string) map[string]string {
+	// De-duplicate so each OUI is only looked up once per run
+	macByOUI := make(map[string]string)
+	for _, mac := range macs {
+		oui, err := OUI(mac)
+		if err != nil {
+			continue
+		}
+		if _, exists := macByOUI[oui]; !exists {
+			macByOUI[oui] = mac
+		}
+	}
+	return lookupVendors(ctx, macByOUI)
+}
+
+// lookupVendors resolves the vendor for each OUI using a bounded pool of
+// workers. macByOUI maps each OUI to a representative MAC address.
+func lookupVendors(ctx context.Context, macByOUI map[string]string) map[string]string {
+	vendorCacheMu.Lock()
+	vendorCache = LoadCache()
+	vendorCacheMu.Unlock()
+	defer flushVendorCache()
+
+	vendors := make(map[string]string, len(macByOUI))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	jobs := make(chan string)
+	for w := 0; w < vendorLookupWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for oui := range jobs {
+				vendor := Lookup(ctx, macByOUI[oui])
+				mu.Lock()
+				vendors[oui] = vendor
+				done := len(vendors)
+				mu.Unlock()
+				ReportProgress(done, len(macByOUI))
+			}
+		}()
+	}
+
+	for oui := range macByOUI {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- oui
+	}
+	close(jobs)
+	wg.Wait()
+
+	return vendors
+}
+
+// Overrides maps uppercase hex MACs (12 digits) and OUIs (6 digits)
+// to user-chosen labels from vendor_overrides.json.
+var Overrides map[string]string
+
+// LoadOverrides reads vendor_overrides.json, whose keys are full MACs
+// or OUIs in any of the usual notations. A missing file is not an error.
+func LoadOverrides() (map[string]string, error) {
+	data, err := config.ReadFile("vendor_overrides.json")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("vendor_overrides.json: %v", err)
+	}
+
+	overrides := make(map[string]string, len(raw))
+	for k, label := range raw {
+		hexKey := strings.ToUpper(strings.NewReplacer(":", "", "-", "", ".", "").Replace(k))
+		if normalized, err := NormalizeMAC(k); err == nil {
+			hexKey = strings.ReplaceAll(normalized, ":", "")
+		} else if _, err := hex.DecodeString(hexKey); err != nil || len(hexKey) != 6 {
+			return nil, fmt.Errorf("vendor_overrides.json: %q is not a MAC address or OUI", k)
+		}
+		overrides[hexKey] = label
+	}
+	return overrides, nil
+}
+
+// For returns the vendor to show for mac: a full-MAC override if
+// there is one, otherwise the vendor resolved for its OUI.
+func For(mac string, vendors map[string]string) string {
+	normalized, err := NormalizeMAC(mac)
+	if err != nil {
+		return ""
+	}
+	hexMAC := strings.ReplaceAll(normalized, ":", "")
+	if label, ok := Overrides[hexMAC]; ok {
+		return label
+	}
+	return vendors[hexMAC[:6]]
+}
+
+// Lookup resolves the vendor of a single MAC from the overrides, the OUI
+// database, the cache or the API, in that order.
+func Lookup(ctx context.Context, mac string) string {
+	// Get first 3 octets for vendor lookup
+	oui, err := OUI(mac)
+	if err != nil {
+		slog.Debug("skipping vendor lookup", "error", err)
+		return "Unknown"
+	}
+
+	// User labels win over every other source
+	if label, ok := Overrides[oui]; ok {
+		return label
+	}
+
+	// Local OUI database avoids the network entirely
+	if vendor, ok := OUIDatabase[oui]; ok {
+		slog.Debug("vendor found in OUI database", "oui", oui)
+		return vendor
+	}
+
+	// Only the first caller for an OUI resolves it; the rest wait for and
+	// share its result for the rest of the run
+	vendorLookupsMu.Lock()
+	if lookup, exists := vendorLookups[oui]; exists {
+		vendorLookupsMu.Unlock()
+		<-lookup.done
+		return lookup.vendor
+	}
+	lookup := &vendorLookup{done: make(chan struct{})}
+	vendorLookups[oui] = lookup
+	vendorLookupsMu.Unlock()
+
+	lookup.vendor = resolveMacVendor(ctx, oui)
+	close(lookup.done)
+
+	// Failed lookups are forgotten so a later refresh can retry them
+	if lookup.vendor == "Unknown" || lookup.vendor == "Rate Limited" {
+		vendorLookupsMu.Lock()
+		delete(vendorLookups, oui)
+		vendorLookupsMu.Unlock()
+	}
+	return lookup.vendor
+}
+
+// LoadOUIDatabase parses an IEEE OUI registry file, either the oui.txt text
+// format or the oui.csv export, into a map keyed by OUI prefix.
+func LoadOUIDatabase(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	db := make(map[string]string)
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		// Registry,Assignment,Organization Name,Organization Address
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+		records, err := r.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if len(record) < 3 || len(record[1]) != 6 {
+				continue
+			}
+			db[strings.ToUpper(record[1])] = strings.TrimSpace(record[2])
+		}
+		return db, nil
+	}
+
+	// 00000C     (base 16)		Cisco Systems, Inc
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		prefix, vendor, found := strings.Cut(scanner.Text(), "(base 16)")
+		if !found {
+			continue
+		}
+		prefix = strings.TrimSpace(prefix)
+		if len(prefix) != 6 {
+			continue
+		}
+		db[strings.ToUpper(prefix)] = strings.TrimSpace(vendor)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
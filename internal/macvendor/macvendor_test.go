@@ -0,0 +1,305 @@
+package macvendor
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ezeql/routeros-misc-tools/internal/config"
+)
+
+// fakeAPI points the package at an httptest server running handler, with
+// the config directory, per-run lookup state and overrides reset. It
+// returns the number of requests the server has answered.
+func fakeAPI(t *testing.T, handler http.HandlerFunc) *atomic.Int32 {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	prevURL, prevReport := APIURL, ReportStatus
+	t.Cleanup(func() { APIURL, ReportStatus = prevURL, prevReport })
+	APIURL, TTL, Timeout = srv.URL+"/%s", DefaultTTL, DefaultTimeout
+	ReportStatus = func(string) {}
+	OUIDatabase, Overrides = nil, nil
+	vendorAPIFailures.Store(0)
+	vendorAPIDisabled.Store(false)
+	vendorCacheLockReported.Store(false)
+	clear(vendorLookups)
+	clear(vendorCacheNew)
+	vendorCache = Cache{Vendors: make(map[string]CacheEntry)}
+	return &requests
+}
+
+// fakeSleep records the backoff waits instead of sleeping
+func fakeSleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var mu sync.Mutex
+	var waits []time.Duration
+	sleep = func(ctx context.Context, d time.Duration) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		waits = append(waits, d)
+		return ctx.Err() == nil
+	}
+	t.Cleanup(func() { sleep = sleepContext })
+	return &waits
+}
+
+func TestResolveCachesVendors(t *testing.T) {
+	requests := fakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/B827EB" {
+			w.Write([]byte("Raspberry Pi Foundation"))
+			return
+		}
+		http.NotFound(w, r)
+	})
+
+	macs := []string{"B8:27:EB:12:34:56", "b8-27-eb-00-00-01", "00:11:32:AA:BB:CC", "not a mac"}
+	want := map[string]string{"B827EB": "Raspberry Pi Foundation", "001132": "Unknown"}
+	got := Resolve(context.Background(), macs)
+	if !maps.Equal(got, want) {
+		t.Errorf("Resolve = %v, want %v", got, want)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("API was called %d times, want once per OUI (2)", n)
+	}
+
+	cache := LoadCache()
+	if entry := cache.Vendors["B827EB"]; entry.Vendor != "Raspberry Pi Foundation" {
+		t.Errorf("cached B827EB = %+v, want the resolved vendor", entry)
+	}
+	if _, ok := cache.Vendors["001132"]; ok {
+		t.Error("unknown vendor was cached")
+	}
+
+	// A new run reads the vendor from the cache file
+	clear(vendorLookups)
+	got = Resolve(context.Background(), macs[:1])
+	if got["B827EB"] != "Raspberry Pi Foundation" {
+		t.Errorf("second Resolve = %v", got)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("API was called %d times, want the cached vendor reused", n)
+	}
+}
+
+func TestResolveTTL(t *testing.T) {
+	tests := []struct {
+		name    string
+		age     time.Duration
+		want    string
+		queries int32
+	}{
+		{"fresh", time.Hour, "Old Name", 0},
+		{"expired", DefaultTTL + time.Hour, "New Name", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := fakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("New Name"))
+			})
+			err := SaveCache(Cache{Vendors: map[string]CacheEntry{
+				"B827EB": {Vendor: "Old Name", Timestamp: time.Now().Add(-tt.age)},
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := Resolve(context.Background(), []string{"B8:27:EB:12:34:56"})
+			if got["B827EB"] != tt.want {
+				t.Errorf("vendor = %q, want %q", got["B827EB"], tt.want)
+			}
+			if n := requests.Load(); n != tt.queries {
+				t.Errorf("API was called %d times, want %d", n, tt.queries)
+			}
+			if entry := LoadCache().Vendors["B827EB"]; entry.Vendor != tt.want {
+				t.Errorf("cache holds %q, want %q", entry.Vendor, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheFile(t *testing.T) {
+	fakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Raspberry Pi Foundation"))
+	})
+	path := filepath.Join(t.TempDir(), "shared.json")
+	config.SetPath("vendor_cache.json", path)
+	t.Cleanup(func() { config.SetPath("vendor_cache.json", "") })
+
+	Resolve(context.Background(), []string{"B8:27:EB:12:34:56"})
+	var cache Cache
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Vendors["B827EB"].Vendor != "Raspberry Pi Foundation" {
+		t.Errorf("%s holds %+v, want the resolved vendor", path, cache.Vendors)
+	}
+}
+
+func TestCacheFileReadOnly(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("root can write read-only files")
+	}
+	requests := fakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("New Name"))
+	})
+	path := filepath.Join(t.TempDir(), "shared.json")
+	shared := `{"vendors":{"001132":{"vendor":"Synology","timestamp":"2099-01-01T00:00:00Z"}}}`
+	if err := os.WriteFile(path, []byte(shared), 0444); err != nil {
+		t.Fatal(err)
+	}
+	config.SetPath("vendor_cache.json", path)
+	t.Cleanup(func() { config.SetPath("vendor_cache.json", "") })
+
+	got := Resolve(context.Background(), []string{"00:11:32:AA:BB:CC", "B8:27:EB:12:34:56"})
+	want := map[string]string{"001132": "Synology", "B827EB": "New Name"}
+	if !maps.Equal(got, want) {
+		t.Errorf("Resolve = %v, want %v", got, want)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("API was called %d times, want only for the OUI missing from the shared cache", n)
+	}
+	if data, _ := os.ReadFile(path); string(data) != shared {
+		t.Errorf("read-only cache was rewritten: %s", data)
+	}
+}
+
+func TestQueryRateLimited(t *testing.T) {
+	tests := []struct {
+		name       string
+		limited    int32  // requests answered with 429 before succeeding
+		retryAfter string // Retry-After header on the 429s
+		want       string
+		waits      []time.Duration
+	}{
+		{"recovers", 1, "", "Acme", []time.Duration{initialBackoff}},
+		{"exponential backoff", 3, "", "Rate Limited", []time.Duration{initialBackoff, 2 * initialBackoff}},
+		{"retry-after seconds", 2, "7", "Acme", []time.Duration{7 * time.Second, 7 * time.Second}},
+		{"retry-after capped", 1, "3600", "Acme", []time.Duration{maxBackoff}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests *atomic.Int32
+			requests = fakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+				if requests.Load() <= tt.limited {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				w.Write([]byte(`{"vendorDetails":{"company":"Acme"}}`))
+			})
+			waits := fakeSleep(t)
+
+			if got := queryMacVendorAPI(context.Background(), "B827EB"); got != tt.want {
+				t.Errorf("vendor = %q, want %q", got, tt.want)
+			}
+			if !slices.Equal(*waits, tt.waits) {
+				t.Errorf("waits = %v, want %v", *waits, tt.waits)
+			}
+		})
+	}
+}
+
+func TestRateLimitedNotCached(t *testing.T) {
+	fakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	fakeSleep(t)
+
+	got := Resolve(context.Background(), []string{"B8:27:EB:12:34:56"})
+	if got["B827EB"] != "Rate Limited" {
+		t.Errorf("vendor = %q, want Rate Limited", got["B827EB"])
+	}
+	if entry, ok := LoadCache().Vendors["B827EB"]; ok {
+		t.Errorf("rate limited lookup was cached as %+v", entry)
+	}
+}
+
+func TestAPIDisabledAfterFailures(t *testing.T) {
+	requests := fakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	var reported []string
+	ReportStatus = func(msg string) { reported = append(reported, msg) }
+
+	for i := 0; i < vendorAPIFailureLimit+2; i++ {
+		queryMacVendorAPI(context.Background(), "B827EB")
+	}
+	if n := requests.Load(); n != vendorAPIFailureLimit {
+		t.Errorf("API was called %d times, want it skipped after %d failures", n, vendorAPIFailureLimit)
+	}
+	if len(reported) != 1 {
+		t.Errorf("reported %q, want a single message", reported)
+	}
+}
+
+func TestParseVendorResponse(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"Raspberry Pi Foundation\n", "Raspberry Pi Foundation"},
+		{`{"vendorDetails":{"company":"Acme"}}`, "Acme"},
+		{`{"company":"Acme"}`, "Acme"},
+		{`{"organization":"Acme"}`, "Acme"},
+		{`{"errors":{"detail":"Not Found"}}`, "Unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := parseVendorResponse([]byte(tt.body)); got != tt.want {
+			t.Errorf("parseVendorResponse(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestMalformedMAC(t *testing.T) {
+	requests := fakeAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Acme"))
+	})
+
+	// Too short to hold an OUI, so slicing one out used to panic
+	for _, mac := range []string{"AA:BB", "", "ZZ:ZZ:ZZ:ZZ:ZZ:ZZ", "AA", "AA:BB:CC:DD:EE"} {
+		if _, err := NormalizeMAC(mac); err == nil {
+			t.Errorf("NormalizeMAC(%q) succeeded", mac)
+		}
+		if _, err := OUI(mac); err == nil {
+			t.Errorf("OUI(%q) succeeded", mac)
+		}
+		if got := Lookup(context.Background(), mac); got != "Unknown" {
+			t.Errorf("Lookup(%q) = %q, want Unknown", mac, got)
+		}
+		if got := For(mac, map[string]string{"AABBCC": "Acme"}); got != "" {
+			t.Errorf("For(%q) = %q, want no vendor", mac, got)
+		}
+	}
+	if got := Resolve(context.Background(), []string{"AA:BB", ""}); len(got) != 0 {
+		t.Errorf("Resolve = %v, want nothing resolved", got)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("API was called %d times for malformed MACs", n)
+	}
+}
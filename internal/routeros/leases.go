@@ -0,0 +1,180 @@
+package routeros
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseSubnets parses a comma-separated list of CIDRs such as
+// "192.168.10.0/24,10.0.0.0/8".
+func ParseSubnets(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, part := range strings.Split(list, ",") {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// InSubnets reports whether addr is an IP address inside any of prefixes
+func InSubnets(addr string, prefixes []netip.Prefix) bool {
+	a, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range prefixes {
+		if prefix.Contains(a) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnknownFormat is returned by ParseLeases for output that is neither
+// terse nor columnar
+var ErrUnknownFormat = errors.New("lease output was neither terse nor columnar")
+
+// ParseLeases parses "/ip dhcp-server lease print terse" output, falling
+// back to the default columnar layout. Output that yields no leases in
+// either form returns ErrUnknownFormat, unless it is empty.
+func ParseLeases(output string) ([]DHCPLease, error) {
+	leases := leasesFromRecords(ParseTerse(output))
+	if len(leases) == 0 && strings.TrimSpace(output) != "" {
+		// Some versions ignore "terse" and print the default columns
+		leases = leasesFromRecords(ParseColumnar(output))
+		if len(leases) == 0 {
+			return nil, ErrUnknownFormat
+		}
+	}
+	return leases, nil
+}
+
+func leasesFromRecords(records []Record) []DHCPLease {
+	var leases []DHCPLease
+	for _, record := range records {
+		lease, ok := LeaseFromFields(record.Fields)
+		if !ok {
+			continue
+		}
+
+		// v6 only reports these through the flags column
+		lease.Flags = record.Flags
+		if strings.ContainsRune(record.Flags, 'D') {
+			lease.Dynamic = true
+		}
+		if strings.ContainsRune(record.Flags, 'X') {
+			lease.Disabled = true
+		}
+		leases = append(leases, lease)
+	}
+	return leases
+}
+
+// LeaseFromFields builds a lease from RouterOS property names, as found in
+// both terse output and REST responses. ok is false for incomplete entries.
+func LeaseFromFields(fields map[string]string) (lease DHCPLease, ok bool) {
+	lease = DHCPLease{
+		ID:         fields[".id"],
+		Address:    fields["address"],
+		MacAddress: fields["mac-address"],
+		Hostname:   fields["host-name"],
+		Dynamic:    fields["dynamic"] == "yes" || fields["dynamic"] == "true",
+		Status:     fields["status"],
+		Disabled:   fields["disabled"] == "yes" || fields["disabled"] == "true",
+		Server:     fields["server"],
+		Comment:    fields["comment"],
+	}
+	lease.Expiry, _ = ParseDuration(fields["expires-after"])
+	return lease, lease.Address != "" && lease.MacAddress != ""
+}
+
+// ParseDuration parses RouterOS durations such as "1w2d3h4m5s" as
+// well as the older "hh:mm:ss" form, optionally prefixed with days.
+func ParseDuration(value string) (time.Duration, error) {
+	if value == "" || value == "never" {
+		return 0, nil
+	}
+
+	if strings.Contains(value, ":") {
+		var total time.Duration
+		days, clock, found := strings.Cut(value, "d")
+		if found {
+			d, err := strconv.Atoi(days)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			total = time.Duration(d) * 24 * time.Hour
+		} else {
+			clock = days
+		}
+		var h, m, sec int
+		if _, err := fmt.Sscanf(clock, "%d:%d:%d", &h, &m, &sec); err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return total + time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second, nil
+	}
+
+	units := map[string]time.Duration{
+		"w":  7 * 24 * time.Hour,
+		"d":  24 * time.Hour,
+		"h":  time.Hour,
+		"m":  time.Minute,
+		"s":  time.Second,
+		"ms": time.Millisecond,
+	}
+
+	var total time.Duration
+	rest := value
+	for rest != "" {
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		j := i
+		for j < len(rest) && (rest[j] < '0' || rest[j] > '9') {
+			j++
+		}
+		n, err := strconv.Atoi(rest[:i])
+		unit, ok := units[rest[i:j]]
+		if err != nil || !ok {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		total += time.Duration(n) * unit
+		rest = rest[j:]
+	}
+	return total, nil
+}
+
+// FormatExpiry renders a lease expiry in RouterOS style, or a dash for
+// leases that never expire.
+func FormatExpiry(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+
+	d = d.Round(time.Second)
+	var b strings.Builder
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{
+		{"w", 7 * 24 * time.Hour},
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+	} {
+		if d >= unit.size {
+			fmt.Fprintf(&b, "%d%s", d/unit.size, unit.suffix)
+			d %= unit.size
+		}
+	}
+	return b.String()
+}
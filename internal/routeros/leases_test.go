@@ -0,0 +1,189 @@
+package routeros
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLeases(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []DHCPLease
+	}{
+		{
+			fixture: "leases_v6_terse.txt",
+			want: []DHCPLease{{
+				Address:    "192.168.88.250",
+				MacAddress: "B8:27:EB:12:34:56",
+				Hostname:   "raspberrypi",
+				Expiry:     8*time.Minute + 12*time.Second,
+				Dynamic:    true,
+				Status:     "bound",
+				Flags:      "D",
+				Server:     "defconf",
+			}, {
+				Address:    "192.168.88.10",
+				MacAddress: "00:11:32:AA:BB:CC",
+				Hostname:   "DiskStation",
+				Status:     "bound",
+				Server:     "defconf",
+				Comment:    "NAS",
+			}, {
+				Address:    "192.168.88.30",
+				MacAddress: "3C:22:FB:AA:00:01",
+				Status:     "waiting",
+				Disabled:   true,
+				Flags:      "X",
+				Server:     "defconf",
+			}, {
+				Address:    "192.168.88.251",
+				MacAddress: "F0:18:98:01:02:03",
+				Hostname:   "Jane's MacBook Pro",
+				Expiry:     9*time.Minute + 33*time.Second,
+				Dynamic:    true,
+				Status:     "bound",
+				Flags:      "D",
+				Server:     "defconf",
+			}},
+		},
+		{
+			fixture: "leases_v7_terse.txt",
+			want: []DHCPLease{{
+				Address:    "192.168.88.254",
+				MacAddress: "D8:07:B6:01:02:03",
+				Hostname:   `café "kiosk"`,
+				Expiry:     9*time.Minute + 33*time.Second,
+				Dynamic:    true,
+				Status:     "bound",
+				Flags:      "D",
+				Server:     "defconf",
+			}, {
+				Address:    "192.168.88.20",
+				MacAddress: "A4:5E:60:10:20:30",
+				Status:     "bound",
+				Server:     "defconf",
+				Comment:    "living room TV, vlan=20",
+			}, {
+				Address:    "10.0.20.5",
+				MacAddress: "00:0C:29:AB:CD:EF",
+				Expiry:     26*time.Hour + 3*time.Minute,
+				Dynamic:    true,
+				Status:     "waiting",
+				Disabled:   true,
+				Flags:      "XD",
+				Server:     "guest",
+			}, {
+				Address:    "10.0.20.6",
+				MacAddress: "00:0C:29:AB:CD:F0",
+				Expiry:     30 * time.Second,
+				Status:     "offered",
+				Flags:      "B",
+				Server:     "guest",
+			}},
+		},
+		{
+			fixture: "leases_v7_columnar.txt",
+			want: []DHCPLease{{
+				Address:    "192.168.88.10",
+				MacAddress: "00:11:32:AA:BB:CC",
+				Hostname:   "DiskStation",
+				Status:     "bound",
+				Server:     "defconf",
+				Comment:    "NAS",
+			}, {
+				Address:    "192.168.88.254",
+				MacAddress: "D8:07:B6:01:02:03",
+				Hostname:   "iPhone",
+				Dynamic:    true,
+				Status:     "bound",
+				Flags:      "D",
+				Server:     "defconf",
+			}, {
+				Address:    "192.168.88.30",
+				MacAddress: "3C:22:FB:AA:00:01",
+				Status:     "waiting",
+				Disabled:   true,
+				Flags:      "X",
+				Server:     "defconf",
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			output, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseLeases(string(output))
+			if err != nil {
+				t.Fatalf("ParseLeases: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d leases, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range tt.want {
+				if !reflect.DeepEqual(got[i], tt.want[i]) {
+					t.Errorf("lease %d:\n got %+v\nwant %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseLeasesUnknownFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		wantErr error
+	}{
+		{"empty", "", nil},
+		{"blank lines", "\n  \n", nil},
+		{"error message", "bad command name lease (line 1 column 17)", ErrUnknownFormat},
+		{"no complete lease", " 0 D address=10.0.0.1 server=guest", ErrUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leases, err := ParseLeases(tt.output)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(leases) != 0 {
+				t.Errorf("got %d leases, want none", len(leases))
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"never", 0},
+		{"45s", 45 * time.Second},
+		{"1w2d3h4m5s", 9*24*time.Hour + 3*time.Hour + 4*time.Minute + 5*time.Second},
+		{"500ms", 500 * time.Millisecond},
+		{"00:10:00", 10 * time.Minute},
+		{"2d01:00:30", 49*time.Hour + 30*time.Second},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v; want %v", tt.value, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []string{"5x", "abc", "1:2"} {
+		if _, err := ParseDuration(value); err == nil {
+			t.Errorf("ParseDuration(%q) succeeded, want an error", value)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package routeros holds the records the tools read from a RouterOS router
+// and parses them from the CLI's print output.
+package routeros
+
+import (
+	"strings"
+	"time"
+)
+
+// DHCPLease is a DHCP server lease, enriched with what the tools found out
+// about its device
+type DHCPLease struct {
+	ID              string        `json:"id,omitempty"` // RouterOS .id, when the source reports it
+	Address         string        `json:"address"`
+	MacAddress      string        `json:"mac_address"`
+	Hostname        string        `json:"hostname"`
+	HostnameFromDNS bool          `json:"hostname_from_dns,omitempty"` // Hostname came from DNS, not the DHCP client
+	Vendor          string        `json:"vendor"`
+	Expiry          time.Duration `json:"expiry,omitempty"` // zero for leases that never expire
+	Dynamic         bool          `json:"dynamic"`
+	Status          string        `json:"status,omitempty"`
+	Disabled        bool          `json:"disabled"`
+	Flags           string        `json:"flags,omitempty"`  // raw terse flags, e.g. "XD"
+	Server          string        `json:"server,omitempty"` // DHCP server that issued the lease
+	Port            string        `json:"port,omitempty"`   // bridge port the MAC was learned on
+	Comment         string        `json:"comment,omitempty"`
+	LastSeen        *time.Time    `json:"last_seen,omitempty"` // last run the MAC was bound or in ARP
+	Error           string        `json:"error,omitempty"`
+}
+
+// ARPEntry is an ARP table entry
+type ARPEntry struct {
+	Address    string `json:"address"`
+	MacAddress string `json:"mac_address"`
+	Interface  string `json:"interface"`
+	Status     string `json:"status,omitempty"` // v7 status, or "invalid" for v6 I-flagged entries
+	Vendor     string `json:"vendor"`
+	HasLease   bool   `json:"has_lease"`
+}
+
+// IPv6Host is a DHCPv6 binding or an IPv6 neighbor table entry
+type IPv6Host struct {
+	Address    string `json:"address"`
+	MacAddress string `json:"mac_address,omitempty"`
+	DUID       string `json:"duid,omitempty"`
+	Interface  string `json:"interface,omitempty"`
+	Vendor     string `json:"vendor"`
+	Status     string `json:"status,omitempty"`
+	Source     string `json:"source"` // "dhcp" or "neighbor"
+}
+
+// Neighbor is a device found by MNDP, CDP or LLDP neighbor discovery
+type Neighbor struct {
+	Interface  string `json:"interface"`
+	Address    string `json:"address,omitempty"`
+	MacAddress string `json:"mac_address"`
+	Identity   string `json:"identity,omitempty"`
+	Platform   string `json:"platform,omitempty"`
+	Version    string `json:"version,omitempty"`
+	Vendor     string `json:"vendor"`
+}
+
+// PoolUsage is how many addresses of an IP pool are held by leases
+type PoolUsage struct {
+	Name   string `json:"name"`
+	Ranges string `json:"ranges"`
+	Size   int    `json:"size"`
+	Used   int    `json:"used"`
+}
+
+// Percent returns the share of the pool in use
+func (p PoolUsage) Percent() float64 {
+	if p.Size == 0 {
+		return 0
+	}
+	return float64(p.Used) * 100 / float64(p.Size)
+}
+
+// InterfaceStat holds the traffic counters of an interface
+type InterfaceStat struct {
+	Name      string `json:"name"`
+	RxBytes   uint64 `json:"rx_bytes"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	TxPackets uint64 `json:"tx_packets"`
+}
+
+// Connection is a connection tracking entry
+type Connection struct {
+	Protocol   string `json:"protocol"`
+	SrcAddress string `json:"src_address"`
+	DstAddress string `json:"dst_address"`
+	State      string `json:"state"`
+}
+
+// SystemResource is the router's board, load and health readings
+type SystemResource struct {
+	BoardName   string   `json:"board_name"`
+	Version     string   `json:"version"`
+	Uptime      string   `json:"uptime"`
+	CPULoad     int      `json:"cpu_load"`
+	FreeMemory  uint64   `json:"free_memory"`
+	TotalMemory uint64   `json:"total_memory"`
+	Temperature *float64 `json:"temperature,omitempty"` // not every board has sensors
+	Voltage     *float64 `json:"voltage,omitempty"`
+}
+
+// Quote quotes s as a RouterOS script string
+func Quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,255 @@
+package routeros
+
+import (
+	"cmp"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ParseARP parses "/ip arp print terse" output
+func ParseARP(output string) []ARPEntry {
+	var entries []ARPEntry
+	for _, record := range ParseTerse(output) {
+		fields := record.Fields
+		entry := ARPEntry{
+			Address:    fields["address"],
+			MacAddress: fields["mac-address"],
+			Interface:  fields["interface"],
+			Status:     fields["status"],
+		}
+		if entry.Status == "" && strings.Contains(record.Flags, "I") {
+			entry.Status = "invalid"
+		}
+		if entry.Address != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// ParseIPv6Hosts parses binding or neighbor terse output. Bindings often
+// carry only a DUID; link-layer DUIDs embed the client MAC, which is used
+// when no mac-address is reported.
+func ParseIPv6Hosts(output, source string) []IPv6Host {
+	var hosts []IPv6Host
+	for _, record := range ParseTerse(output) {
+		fields := record.Fields
+		host := IPv6Host{
+			Address:    fields["address"],
+			MacAddress: fields["mac-address"],
+			DUID:       fields["duid"],
+			Interface:  cmp.Or(fields["interface"], fields["server"]),
+			Status:     fields["status"],
+			Source:     source,
+		}
+		if host.MacAddress == "" {
+			host.MacAddress = duidMAC(host.DUID)
+		}
+		if host.Address != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// duidMAC extracts the MAC address from a DUID-LLT or DUID-LL with an
+// Ethernet hardware type, given in RouterOS's "0x0001..." hex form.
+// Other DUID types return "".
+func duidMAC(duid string) string {
+	b, err := hex.DecodeString(strings.TrimPrefix(strings.ReplaceAll(duid, ":", ""), "0x"))
+	if err != nil || len(b) < 4 || b[2] != 0x00 || b[3] != 0x01 {
+		return ""
+	}
+	var mac []byte
+	switch {
+	case b[0] == 0x00 && b[1] == 0x01 && len(b) == 14: // DUID-LLT: type, hw type, time, MAC
+		mac = b[8:]
+	case b[0] == 0x00 && b[1] == 0x03 && len(b) == 10: // DUID-LL: type, hw type, MAC
+		mac = b[4:]
+	default:
+		return ""
+	}
+	return strings.ToUpper(net.HardwareAddr(mac).String())
+}
+
+// ParseNeighbors parses "/ip neighbor print terse" output
+func ParseNeighbors(output string) []Neighbor {
+	var neighbors []Neighbor
+	for _, record := range ParseTerse(output) {
+		fields := record.Fields
+		n := Neighbor{
+			Interface:  fields["interface"],
+			Address:    cmp.Or(fields["address"], fields["address4"]),
+			MacAddress: fields["mac-address"],
+			Identity:   fields["identity"],
+			Platform:   fields["platform"],
+			Version:    fields["version"],
+		}
+		if n.MacAddress != "" {
+			neighbors = append(neighbors, n)
+		}
+	}
+	return neighbors
+}
+
+// addrRange is an inclusive range of IPv4 addresses
+type addrRange struct {
+	first, last uint32
+}
+
+// parsePoolRanges parses a pool's ranges property, a comma-separated list
+// of "a.b.c.d-e.f.g.h" ranges, single addresses or CIDR prefixes.
+func parsePoolRanges(ranges string) ([]addrRange, error) {
+	var out []addrRange
+	for _, part := range strings.Split(ranges, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(part); err == nil && prefix.Addr().Is4() {
+			first := ipv4Uint(prefix.Masked().Addr())
+			out = append(out, addrRange{first, first | (1<<(32-prefix.Bits()) - 1)})
+			continue
+		}
+		from, to, found := strings.Cut(part, "-")
+		if !found {
+			to = from
+		}
+		a, err := netip.ParseAddr(from)
+		if err != nil || !a.Is4() {
+			return nil, fmt.Errorf("invalid pool range %q", part)
+		}
+		b, err := netip.ParseAddr(to)
+		if err != nil || !b.Is4() || b.Less(a) {
+			return nil, fmt.Errorf("invalid pool range %q", part)
+		}
+		out = append(out, addrRange{ipv4Uint(a), ipv4Uint(b)})
+	}
+	return out, nil
+}
+
+func ipv4Uint(a netip.Addr) uint32 {
+	b := a.As4()
+	return binary.BigEndian.Uint32(b[:])
+}
+
+// ParsePoolUsage counts the enabled leases that fall inside each pool
+func ParsePoolUsage(output string, leases []DHCPLease) ([]PoolUsage, error) {
+	var addrs []uint32
+	for _, lease := range leases {
+		if a, err := netip.ParseAddr(lease.Address); err == nil && a.Is4() && !lease.Disabled {
+			addrs = append(addrs, ipv4Uint(a))
+		}
+	}
+
+	var pools []PoolUsage
+	for _, record := range ParseTerse(output) {
+		pool := PoolUsage{Name: record.Fields["name"], Ranges: record.Fields["ranges"]}
+		if pool.Name == "" {
+			continue
+		}
+		ranges, err := parsePoolRanges(pool.Ranges)
+		if err != nil {
+			return nil, fmt.Errorf("pool %s: %v", pool.Name, err)
+		}
+		for _, r := range ranges {
+			pool.Size += int(r.last-r.first) + 1
+		}
+		for _, a := range addrs {
+			for _, r := range ranges {
+				if a >= r.first && a <= r.last {
+					pool.Used++
+					break
+				}
+			}
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+// ParseInterfaceStats parses "/interface print stats terse" output
+func ParseInterfaceStats(output string) []InterfaceStat {
+	var stats []InterfaceStat
+	for _, record := range ParseTerse(output) {
+		fields := record.Fields
+		if fields["name"] == "" {
+			continue
+		}
+		stat := InterfaceStat{Name: fields["name"]}
+		stat.RxBytes, _ = strconv.ParseUint(fields["rx-byte"], 10, 64)
+		stat.TxBytes, _ = strconv.ParseUint(fields["tx-byte"], 10, 64)
+		stat.RxPackets, _ = strconv.ParseUint(fields["rx-packet"], 10, 64)
+		stat.TxPackets, _ = strconv.ParseUint(fields["tx-packet"], 10, 64)
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// FormatBytes renders a byte count using binary units
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for v := n / unit; v >= unit && exp < 4; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTP"[exp])
+}
+
+// ParseBytes reverses FormatBytes for sorting
+func ParseBytes(value string) (float64, bool) {
+	// Accept both "1.5 MiB" and RouterOS' own "1.5MiB"
+	i := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i <= 0 {
+		return 0, false
+	}
+	number, suffix := value[:i], strings.TrimSpace(value[i:])
+	if suffix == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, false
+	}
+	if suffix == "B" {
+		return n, true
+	}
+	exp := strings.IndexByte("KMGTP", suffix[0])
+	if exp < 0 || !strings.HasSuffix(suffix, "iB") {
+		return 0, false
+	}
+	return n * math.Pow(1024, float64(exp+1)), true
+}
+
+// ParseConnections parses "/ip firewall connection print terse" output
+func ParseConnections(output string) []Connection {
+	var conns []Connection
+	for _, record := range ParseTerse(output) {
+		fields := record.Fields
+		conn := Connection{
+			Protocol:   fields["protocol"],
+			SrcAddress: fields["src-address"],
+			DstAddress: fields["dst-address"],
+			State:      fields["tcp-state"],
+		}
+		if conn.State == "" {
+			conn.State = "-"
+		}
+		if conn.SrcAddress != "" {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
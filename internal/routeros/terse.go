@@ -0,0 +1,228 @@
+package routeros
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Record is one line of "print terse" output, e.g.
+// "3 X D address=10.0.0.5 mac-address=...".
+type Record struct {
+	Index  int    // leading item number, -1 if absent
+	Flags  string // flag letters such as "XD"
+	Fields map[string]string
+}
+
+// ParseTerse splits "print terse" output into one record per line. The
+// "Flags:" legend RouterOS v6 prints first is skipped.
+func ParseTerse(output string) []Record {
+	var records []Record
+	lines := strings.Split(output, "\n")
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "Flags:") {
+			continue
+		}
+
+		record := Record{Index: -1, Fields: make(map[string]string)}
+		for i, part := range splitTerseLine(line) {
+			key, value, found := strings.Cut(part, "=")
+			switch {
+			case found:
+				record.Fields[key] = value
+			case len(record.Fields) > 0:
+				// Stray token after the properties started; ignore it
+			case i == 0 && isDigits(part):
+				record.Index, _ = strconv.Atoi(part)
+			case isLetters(part):
+				record.Flags += part
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// ParseColumnar parses the default "print" table, e.g.
+//
+//	Flags: X - disabled, D - dynamic
+//	 #   ADDRESS       MAC-ADDRESS        HOST-NAME
+//	 0 D 10.0.0.5      AA:BB:CC:DD:EE:FF  phone
+//
+// Values are cut at the header's column offsets and keyed by the lowercased
+// column name, so the records match ParseTerse's. ";;; " comment lines
+// apply to the row that follows.
+func ParseColumnar(output string) []Record {
+	var records []Record
+	var names []string
+	var starts []int
+	comment := ""
+
+	for _, line := range strings.Split(output, "\n") {
+		runes := []rune(strings.TrimRight(line, " \r"))
+		trimmed := strings.TrimSpace(string(runes))
+		switch {
+		case trimmed == "", strings.HasPrefix(trimmed, "Flags:"), strings.HasPrefix(trimmed, "Columns:"):
+			continue
+		case names == nil:
+			if !strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			names, starts = columnarHeader(runes)
+			continue
+		case strings.HasPrefix(trimmed, ";;;"):
+			comment = strings.TrimSpace(strings.TrimPrefix(trimmed, ";;;"))
+			continue
+		}
+
+		record := Record{Index: -1, Fields: make(map[string]string)}
+		for _, part := range strings.Fields(string(runes[:min(starts[0], len(runes))])) {
+			switch {
+			case record.Index < 0 && isDigits(part):
+				record.Index, _ = strconv.Atoi(part)
+			case isLetters(part):
+				record.Flags += part
+			}
+		}
+		for i, name := range names {
+			start, end := starts[i], len(runes)
+			if i+1 < len(starts) {
+				end = starts[i+1]
+			}
+			if start >= len(runes) {
+				break
+			}
+			if value := strings.TrimSpace(string(runes[start:min(end, len(runes))])); value != "" {
+				record.Fields[name] = value
+			}
+		}
+		if comment != "" {
+			record.Fields["comment"] = comment
+			comment = ""
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// columnarHeader returns the lowercased column names of a "#   ADDRESS ..."
+// header line and the rune offset each one starts at.
+func columnarHeader(header []rune) (names []string, starts []int) {
+	for i := 0; i < len(header); i++ {
+		if header[i] == ' ' || header[i] == '#' || (i > 0 && header[i-1] != ' ') {
+			continue
+		}
+		end := i
+		for end < len(header) && header[end] != ' ' {
+			end++
+		}
+		names = append(names, strings.ToLower(string(header[i:end])))
+		starts = append(starts, i)
+	}
+	return names, starts
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+func isLetters(s string) bool {
+	return s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return (r < 'A' || r > 'Z') && (r < 'a' || r > 'z')
+	}) < 0
+}
+
+// splitTerseLine splits a terse line on unquoted whitespace. Quotes are
+// removed, so host-name="My Phone" yields host-name=My Phone, and RouterOS
+// escapes inside quotes (\", \\ and \XX hex bytes) are decoded.
+func splitTerseLine(line string) []string {
+	var parts []string
+	var b strings.Builder
+	inQuotes, quoted := false, false
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && inQuotes && i+1 < len(line):
+			if i+2 < len(line) && isHex(line[i+1]) && isHex(line[i+2]) {
+				n, _ := strconv.ParseUint(line[i+1:i+3], 16, 8)
+				b.WriteByte(byte(n))
+				i += 2
+			} else {
+				i++
+				b.WriteByte(line[i])
+			}
+		case c == '"':
+			inQuotes = !inQuotes
+			quoted = true
+		case (c == ' ' || c == '\t') && !inQuotes:
+			if b.Len() > 0 || quoted {
+				parts = append(parts, b.String())
+			}
+			b.Reset()
+			quoted = false
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if b.Len() > 0 || quoted {
+		parts = append(parts, b.String())
+	}
+	return parts
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'A' && c <= 'F') || (c >= 'a' && c <= 'f')
+}
+
+// ParseKeyValues parses the "key: value" layout of non-terse print output
+func ParseKeyValues(output string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if key == "" || strings.Contains(key, " ") {
+			continue
+		}
+		fields[key] = strings.TrimSpace(value)
+	}
+	return fields
+}
+
+// ParseHealth reads numeric health readings from either the RouterOS v6
+// "voltage: 24.1V" layout or the v7 "# NAME VALUE TYPE" table.
+func ParseHealth(output string) map[string]float64 {
+	readings := make(map[string]float64)
+	number := func(value string) (float64, bool) {
+		value = strings.TrimRight(value, "VCF% ")
+		n, err := strconv.ParseFloat(value, 64)
+		return n, err == nil
+	}
+
+	for key, value := range ParseKeyValues(output) {
+		if n, ok := number(value); ok {
+			readings[key] = n
+		}
+	}
+	if len(readings) > 0 {
+		return readings
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		cols := strings.Fields(line)
+		if len(cols) < 3 {
+			continue
+		}
+		if _, err := strconv.Atoi(cols[0]); err != nil {
+			continue
+		}
+		if n, ok := number(cols[2]); ok {
+			readings[cols[1]] = n
+		}
+	}
+	return readings
+}
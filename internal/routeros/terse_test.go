@@ -0,0 +1,111 @@
+package routeros
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSplitTerseLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{"plain", "0 D address=10.0.0.5 status=bound", []string{"0", "D", "address=10.0.0.5", "status=bound"}},
+		{"quoted spaces", `host-name="My Phone" status=bound`, []string{"host-name=My Phone", "status=bound"}},
+		{"escaped quotes", `host-name="say \"hi\"" x=1`, []string{`host-name=say "hi"`, "x=1"}},
+		{"escaped backslash", `path="C:\\temp"`, []string{`path=C:\temp`}},
+		{"hex escape", `host-name="caf\C3\A9"`, []string{"host-name=café"}},
+		{"empty value", `address-lists="" server=defconf`, []string{"address-lists=", "server=defconf"}},
+		{"comment with equals", `comment="TV, vlan=20" address=10.0.0.5`, []string{"comment=TV, vlan=20", "address=10.0.0.5"}},
+		{"comment with separators", "1   comment=NAS\taddress=10.0.0.10", []string{"1", "comment=NAS", "address=10.0.0.10"}},
+		{"unterminated quote", `comment="open ended`, []string{"comment=open ended"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitTerseLine(tt.line); !slices.Equal(got, tt.want) {
+				t.Errorf("splitTerseLine(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTerseIndexFlags(t *testing.T) {
+	type indexFlags struct {
+		index int
+		flags string
+	}
+	tests := []struct {
+		fixture string
+		want    []indexFlags
+	}{
+		{"leases_v6_terse.txt", []indexFlags{{0, "D"}, {1, ""}, {2, "X"}, {3, "D"}}},
+		{"leases_v7_terse.txt", []indexFlags{{0, "D"}, {1, ""}, {2, "XD"}, {3, "B"}, {4, "D"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			output, err := os.ReadFile(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			records := ParseTerse(string(output))
+			var got []indexFlags
+			for _, r := range records {
+				got = append(got, indexFlags{r.Index, r.Flags})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("index and flags = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseColumnar(t *testing.T) {
+	output, err := os.ReadFile(filepath.Join("testdata", "leases_v7_columnar.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := []rune("#   ADDRESS         MAC-ADDRESS        HOST-NAME    SERVER   STATUS  LAST-SEEN")
+	names, starts := columnarHeader(header)
+	wantNames := []string{"address", "mac-address", "host-name", "server", "status", "last-seen"}
+	wantStarts := []int{4, 20, 39, 52, 61, 69}
+	if !slices.Equal(names, wantNames) || !slices.Equal(starts, wantStarts) {
+		t.Errorf("columnarHeader = %v %v, want %v %v", names, starts, wantNames, wantStarts)
+	}
+
+	records := ParseColumnar(string(output))
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3: %+v", len(records), records)
+	}
+	tests := []struct {
+		index  int
+		flags  string
+		fields map[string]string
+	}{
+		{0, "", map[string]string{
+			"address": "192.168.88.10", "mac-address": "00:11:32:AA:BB:CC", "host-name": "DiskStation",
+			"server": "defconf", "status": "bound", "last-seen": "5m1s", "comment": "NAS",
+		}},
+		{1, "D", map[string]string{
+			"address": "192.168.88.254", "mac-address": "D8:07:B6:01:02:03", "host-name": "iPhone",
+			"server": "defconf", "status": "bound", "last-seen": "27s",
+		}},
+		// HOST-NAME is blank; the columns after it keep their offsets
+		{2, "X", map[string]string{
+			"address": "192.168.88.30", "mac-address": "3C:22:FB:AA:00:01",
+			"server": "defconf", "status": "waiting", "last-seen": "never",
+		}},
+	}
+	for i, tt := range tests {
+		r := records[i]
+		if r.Index != tt.index || r.Flags != tt.flags || !maps.Equal(r.Fields, tt.fields) {
+			t.Errorf("record %d = %d %q %v, want %d %q %v", i, r.Index, r.Flags, r.Fields, tt.index, tt.flags, tt.fields)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package tui
+
+import (
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+)
+
+// PrintPlain writes rows under a header of column titles, aligned with
+// spaces, leaving out the hidden columns. Empty cells print as "-" so every
+// line has the same number of fields.
+func PrintPlain(w io.Writer, columns []table.Column, rows []table.Row, hidden []string) error {
+	var visible []int
+	var header []string
+	for i, col := range columns {
+		if !slices.Contains(hidden, col.Title) {
+			visible = append(visible, i)
+			header = append(header, col.Title)
+		}
+	}
+
+	clean := strings.NewReplacer("\t", " ", "\n", " ", "\r", "")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		cells := make([]string, len(visible))
+		for c, i := range visible {
+			cells[c] = cmp.Or(clean.Replace(row[i]), "-")
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// exportCSV writes the displayed rows, in their current order, to a
+// timestamped CSV file and returns its path.
+func (m Model) exportCSV() (string, error) {
+	path := fmt.Sprintf("%s-%s.csv", m.name, time.Now().Format("20060102-150405"))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	var header []string
+	for _, col := range m.table.Columns() {
+		header = append(header, col.Title)
+	}
+	if err := w.Write(header); err != nil {
+		return "", err
+	}
+	for _, row := range m.table.Rows() {
+		if err := w.Write(row); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// exportTSV writes the displayed rows, in their current order, to a
+// tab-separated file in the temp directory and returns its path.
+func (m Model) exportTSV() (string, error) {
+	f, err := os.CreateTemp("", m.name+"-*.tsv")
+	if err != nil {
+		return "", err
+	}
+
+	var header []string
+	for _, col := range m.table.Columns() {
+		header = append(header, col.Title)
+	}
+	if _, err := io.WriteString(f, tsvTable(header, m.table.Rows())); err != nil {
+		f.Close()
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// tsvTable renders rows as tab-separated lines under a header line. Tabs and
+// newlines inside cells become spaces so every row stays one record.
+func tsvTable(header []string, rows []table.Row) string {
+	escape := strings.NewReplacer("\t", " ", "\n", " ", "\r", "")
+	line := func(cells []string) string {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = escape.Replace(cell)
+		}
+		return strings.Join(escaped, "\t") + "\n"
+	}
+
+	var b strings.Builder
+	b.WriteString(line(header))
+	for _, row := range rows {
+		b.WriteString(line(row))
+	}
+	return b.String()
+}
+
+// exportMarkdown writes the displayed rows, in their current order, to a
+// timestamped GitHub-flavored Markdown table and returns its path.
+func (m Model) exportMarkdown() (string, error) {
+	path := fmt.Sprintf("%s-%s.md", m.name, time.Now().Format("20060102-150405"))
+	var header []string
+	for _, col := range m.table.Columns() {
+		header = append(header, col.Title)
+	}
+	if err := os.WriteFile(path, []byte(markdownTable(header, m.table.Rows())), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// markdownTable renders rows as a Markdown table, escaping pipes so cell
+// text can't split columns.
+func markdownTable(header []string, rows []table.Row) string {
+	escape := strings.NewReplacer("|", `\|`, "\n", " ", "\r", "")
+	line := func(cells []string) string {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = escape.Replace(cell)
+		}
+		return "| " + strings.Join(escaped, " | ") + " |\n"
+	}
+
+	var b strings.Builder
+	b.WriteString(line(header))
+	sep := make([]string, len(header))
+	for i := range sep {
+		sep[i] = "---"
+	}
+	b.WriteString(line(sep))
+	for _, row := range rows {
+		b.WriteString(line(row))
+	}
+	return b.String()
+}
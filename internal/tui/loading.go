@@ -0,0 +1,112 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ReportProgress is called as vendor lookups complete. It is a no-op
+// unless a loading screen is showing.
+var ReportProgress = func(done, total int) {}
+
+// progressMsg carries vendor lookup progress to the loading screen
+type progressMsg struct {
+	done, total int
+}
+
+// loadingModel shows a spinner while the initial rows are fetched
+type loadingModel struct {
+	spinner   spinner.Model
+	label     string
+	load      func() ([]table.Row, error)
+	progress  progressMsg
+	status    string // latest ReportStatus message, e.g. a rate limit wait
+	rows      []table.Row
+	err       error
+	cancelled bool
+	done      bool
+}
+
+// LoadRows runs load behind a spinner that reports vendor lookup progress
+// and ReportStatus messages, so the first fetch doesn't look like a hang.
+func LoadRows(label string, load func() ([]table.Row, error)) ([]table.Row, error) {
+	if !Interactive() {
+		return load()
+	}
+
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	p := tea.NewProgram(loadingModel{spinner: s, label: label, load: load})
+
+	prev := ReportProgress
+	ReportProgress = func(done, total int) {
+		p.Send(progressMsg{done: done, total: total})
+	}
+	defer func() {
+		ReportProgress = prev
+	}()
+	defer RedirectStatus(p)()
+
+	final, err := RunProgram(p)
+	if err != nil {
+		return nil, err
+	}
+	m := final.(loadingModel)
+	if m.cancelled {
+		return nil, fmt.Errorf("cancelled")
+	}
+	return m.rows, m.err
+}
+
+// Init implements tea.Model
+func (m loadingModel) Init() tea.Cmd {
+	load := m.load
+	return tea.Batch(m.spinner.Tick, func() tea.Msg {
+		rows, err := load()
+		return rowsMsg{rows: rows, err: err}
+	})
+}
+
+// Update implements tea.Model
+func (m loadingModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			m.cancelled, m.done = true, true
+			return m, tea.Quit
+		}
+	case progressMsg:
+		m.progress = msg
+	case StatusMsg:
+		m.status = string(msg)
+	case rowsMsg:
+		m.rows, m.err = msg.rows, msg.err
+		m.done = true
+		return m, tea.Quit
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// View implements tea.Model
+func (m loadingModel) View() string {
+	// Clear the spinner once loading finishes
+	if m.done {
+		return ""
+	}
+	text := m.label
+	if m.progress.total > 0 {
+		text = fmt.Sprintf("Looking up %d vendors... (%d/%d)",
+			m.progress.total, m.progress.done, m.progress.total)
+	}
+	if m.status != "" {
+		text += "\n  " + m.status
+	}
+	return fmt.Sprintf("\n%s %s\n", m.spinner.View(), text)
+}
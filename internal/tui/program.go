@@ -0,0 +1,58 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ReportStatus surfaces background progress such as reconnect attempts.
+// While a TUI is running it is redirected to the status line.
+var ReportStatus = func(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+}
+
+// tuiActive is set while a bubbletea program owns the terminal
+var tuiActive atomic.Bool
+
+// RunProgram runs p, noting that it owns the terminal so shutdown doesn't
+// exit underneath it with the terminal still in raw mode
+func RunProgram(p *tea.Program) (tea.Model, error) {
+	tuiActive.Store(true)
+	defer tuiActive.Store(false)
+	return p.Run()
+}
+
+// Active reports whether a program started by RunProgram owns the terminal
+func Active() bool {
+	return tuiActive.Load()
+}
+
+// StatusMsg replaces the status line of the running program
+type StatusMsg string
+
+// RedirectStatus sends ReportStatus messages to p until the returned
+// function restores the previous behaviour.
+func RedirectStatus(p *tea.Program) func() {
+	prev := ReportStatus
+	ReportStatus = func(msg string) {
+		p.Send(StatusMsg(msg))
+	}
+	return func() {
+		ReportStatus = prev
+	}
+}
+
+// RouterLabel names the connected router in viewer headers
+var RouterLabel string
+
+// RouterHeader renders RouterLabel as a header line, or "" before connecting
+func RouterHeader() string {
+	if RouterLabel == "" {
+		return ""
+	}
+	return lipgloss.NewStyle().Bold(true).Render(RouterLabel) + "\n"
+}
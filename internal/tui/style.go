@@ -0,0 +1,161 @@
+package tui
+
+import (
+	"cmp"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"golang.org/x/term"
+
+	"github.com/ezeql/routeros-misc-tools/internal/config"
+)
+
+// noColor is set at startup when output is plain text: when asked for,
+// under the NO_COLOR convention, or when stdout isn't a terminal.
+var noColor bool
+
+// SetupColor switches lipgloss to plain text when colors are off, so no
+// escape codes are written at all. disable turns them off regardless.
+func SetupColor(disable bool) {
+	noColor = disable || os.Getenv("NO_COLOR") != "" || !term.IsTerminal(int(os.Stdout.Fd()))
+	if noColor {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
+// Interactive reports whether stdin and stdout are both terminals,
+// which the menu and the TUI viewers need. From cron or a pipe the viewers
+// print their output once instead.
+func Interactive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// ColorEnabled reports whether rows are colored
+func ColorEnabled() bool {
+	return !noColor
+}
+
+// asciiBorder replaces box-drawing borders in plain output, for terminals
+// that can't draw them
+var asciiBorder = lipgloss.Border{
+	Top: "-", Bottom: "-", Left: "|", Right: "|",
+	TopLeft: "+", TopRight: "+", BottomLeft: "+", BottomRight: "+",
+}
+
+// Border returns b, or asciiBorder in plain output
+func Border(b lipgloss.Border) lipgloss.Border {
+	if noColor {
+		return asciiBorder
+	}
+	return b
+}
+
+// WarningText highlights a value past its warning threshold in red, or
+// marks it with "!" in plain output where colors are off.
+func WarningText(s string) string {
+	if noColor {
+		return s + " (!)"
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true).Render(s)
+}
+
+// vendorPalette colors vendors without an entry in vendor_colors.json. The
+// colors are picked to stay readable on dark and light backgrounds.
+var vendorPalette = []lipgloss.Color{"39", "42", "170", "208", "81", "141", "203", "112", "220", "75"}
+
+// dimStyle is used for rows whose vendor couldn't be resolved
+var dimStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+
+// vendorStyle returns the row color for vendor: the first vendor_colors.json
+// entry contained in its name, else a palette color derived from the name so
+// each vendor keeps its color across runs. Unknown vendors are dimmed.
+func vendorStyle(vendor string) lipgloss.Style {
+	switch vendor {
+	case "", "Unknown", "Rate Limited":
+		return dimStyle
+	}
+	lower := strings.ToLower(vendor)
+	for _, vc := range vendorColors {
+		if strings.Contains(lower, vc.match) {
+			return lipgloss.NewStyle().Foreground(vc.color)
+		}
+	}
+	h := fnv.New32a()
+	h.Write([]byte(vendor))
+	return lipgloss.NewStyle().Foreground(vendorPalette[h.Sum32()%uint32(len(vendorPalette))])
+}
+
+// vendorColor is a vendor_colors.json entry: rows whose vendor contains
+// match (lowercase) are drawn in color.
+type vendorColor struct {
+	match string
+	color lipgloss.Color
+}
+
+// vendorColors holds the vendor_colors.json entries, longest match first so
+// specific names win over generic ones.
+var vendorColors []vendorColor
+
+// LoadVendorColors sets the row colors from vendor_colors.json
+func LoadVendorColors() error {
+	colors, err := loadVendorColors()
+	if err != nil {
+		return err
+	}
+	vendorColors = colors
+	return nil
+}
+
+// loadVendorColors reads vendor_colors.json, mapping vendor name fragments
+// to ANSI color numbers or hex colors. A missing file is not an error.
+func loadVendorColors() ([]vendorColor, error) {
+	data, err := config.ReadFile("vendor_colors.json")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("vendor_colors.json: %v", err)
+	}
+
+	var colors []vendorColor
+	for match, color := range raw {
+		if _, err := strconv.Atoi(color); err != nil && !validHexColor(color) {
+			return nil, fmt.Errorf("vendor_colors.json: %q is not an ANSI color number or #rrggbb", color)
+		}
+		colors = append(colors, vendorColor{strings.ToLower(match), lipgloss.Color(color)})
+	}
+	slices.SortFunc(colors, func(a, b vendorColor) int {
+		return cmp.Or(len(b.match)-len(a.match), strings.Compare(a.match, b.match))
+	})
+	return colors, nil
+}
+
+// validHexColor reports whether s is a #rrggbb color
+func validHexColor(s string) bool {
+	if len(s) != 7 || s[0] != '#' {
+		return false
+	}
+	_, err := hex.DecodeString(s[1:])
+	return err == nil
+}
+
+// HelpStyle frames the key binding overlay
+func HelpStyle() lipgloss.Style {
+	return lipgloss.NewStyle().
+		Border(Border(lipgloss.RoundedBorder())).
+		BorderForeground(lipgloss.Color("240")).
+		Padding(0, 1)
+}
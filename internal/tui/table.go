@@ -0,0 +1,1143 @@
+// Package tui is the interactive table viewer the tools share, with the
+// styling and terminal helpers around it.
+package tui
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+
+	"github.com/ezeql/routeros-misc-tools/internal/routeros"
+)
+
+// Settings shared by every table, set from the command line before the
+// first one runs
+var (
+	WatchInterval time.Duration     // auto-refresh interval, 0 for none
+	PageSize      int               // rows per page, 0 to fit the terminal
+	ExpiryWarning = 2 * time.Minute // leases expiring sooner are highlighted
+	SSHUser       string            // user for ssh commands copied with h
+)
+
+// Table describes what PrintTable shows
+type Table struct {
+	Name    string // prefixes export files, e.g. "leases"
+	Noun    string // what the summary counts rows as, "rows" if empty
+	Columns []table.Column
+	Rows    []table.Row
+	Fetch   func() ([]table.Row, error) // reloads the rows on refresh
+	Actions []Action                    // extra keys that act on the selected row
+	Hidden  []string                    // titles of columns that start hidden
+	Note    string                      // header line, e.g. about rows filtered out at the source
+}
+
+// PrintTable runs the interactive table for tbl, or prints it once as plain
+// text without a terminal.
+func PrintTable(tbl Table) {
+	// Create and style the table
+	t := table.New(
+		table.WithColumns(tbl.Columns),
+		table.WithFocused(true),
+		table.WithKeyMap(keys.Table),
+	)
+
+	s := table.DefaultStyles()
+	s.Header = s.Header.
+		BorderStyle(Border(lipgloss.NormalBorder())).
+		BorderForeground(lipgloss.Color("240")).
+		BorderBottom(true).
+		Bold(true)
+	s.Selected = s.Selected.
+		Foreground(lipgloss.Color("229")).
+		Background(lipgloss.Color("57")).
+		Bold(false)
+	t.SetStyles(s)
+
+	filter := textinput.New()
+	filter.Prompt = "/"
+	filter.Placeholder = "filter"
+
+	// Widths adjusted with + and - earlier in the run still apply
+	columns := slices.Clone(tbl.Columns)
+	for i, col := range columns {
+		if width, ok := columnWidths[tbl.Name+"/"+col.Title]; ok {
+			columns[i].Width = width
+		}
+	}
+
+	// Initialize model
+	m := Model{
+		table:         t,
+		name:          tbl.Name,
+		noun:          cmp.Or(tbl.Noun, "rows"),
+		note:          tbl.Note,
+		fetch:         tbl.Fetch,
+		columns:       columns,
+		hidden:        make(map[string]bool),
+		help:          help.New(),
+		filter:        filter,
+		watchInterval: WatchInterval,
+		actions:       tbl.Actions,
+	}
+	for _, title := range tbl.Hidden {
+		if m.ColumnIndex(title) >= 0 {
+			m.hidden[title] = true
+		}
+	}
+	m.resetSort()
+	m.setRows(tbl.Rows) // Initial filter and sort
+
+	// Without a terminal print the table once, as sorted by default
+	if !Interactive() {
+		if err := PrintPlain(os.Stdout, m.table.Columns(), m.table.Rows(), nil); err != nil {
+			fmt.Printf("Error printing table: %v\n", err)
+		}
+		return
+	}
+
+	// Initialize bubbletea program
+	p := tea.NewProgram(m)
+	defer RedirectStatus(p)()
+	final, err := RunProgram(p)
+	if err != nil {
+		fmt.Printf("Error running program: %v", err)
+		return
+	}
+
+	// The table owns the terminal while it runs, so rows asked for with T
+	// are written to a file once it's gone
+	if fm, ok := final.(Model); ok && fm.tsvOnQuit {
+		path, err := fm.exportTSV()
+		if err != nil {
+			fmt.Printf("TSV export failed: %v\n", err)
+			return
+		}
+		fmt.Printf("Wrote %d rows to %s\n", len(fm.table.Rows()), path)
+	}
+}
+
+// Model represents the UI state
+type Model struct {
+	table           table.Model
+	name            string
+	noun            string // what the summary counts rows as
+	note            string // extra header line
+	fetch           func() ([]table.Row, error)
+	columns         []table.Column  // all columns, including hidden ones
+	hidden          map[string]bool // titles of hidden columns
+	columnMenu      bool            // next digit toggles a column
+	rows            []table.Row     // all rows, before filtering
+	shown           []table.Row     // full rows as displayed, in order
+	filter          textinput.Model
+	filtering       bool   // filter input has focus
+	typeFilter      string // "", "static" or "dynamic"
+	staleOnly       bool   // only expired and expiring leases
+	serverFilter    string // "" or a DHCP server name
+	sortColumn      int
+	secondaryColumn int // tie-breaker, sorted ascending; -1 for none
+	sortAscending   bool
+	status          string
+	refreshing      bool
+	watchInterval   time.Duration
+	watchPaused     bool
+	width           int // terminal size, 0 until known
+	height          int
+	help            help.Model
+	showHelp        bool
+	rowStyles       map[int]lipgloss.Style // displayed row index to its color
+
+	actions        []Action
+	pendingAction  *Action   // awaiting confirmation
+	pendingRow     table.Row // row the pending action applies to
+	pendingPrompts []string  // confirmations still to answer
+	keepStatus     bool      // next refresh keeps the action result
+
+	tsvOnQuit bool // write the visible rows as TSV after quitting
+}
+
+// Action is a key that runs a command against the selected row after
+// the user confirms each of its prompts.
+type Action struct {
+	Binding key.Binding
+	// Prompts returns the confirmation questions for row, or an error if
+	// the action doesn't apply to it
+	Prompts func(m Model, row table.Row) ([]string, error)
+	Run     func(m Model, row table.Row) (string, error)
+}
+
+// actionMsg carries the result of a row action
+type actionMsg struct {
+	output string
+	err    error
+}
+
+// keyMap lists the table viewer's key bindings. The help overlay is
+// generated from it, so new actions only need a binding here.
+type keyMap struct {
+	Table          table.KeyMap
+	SortPrev       key.Binding
+	SortNext       key.Binding
+	SortOrder      key.Binding
+	SortSecondary  key.Binding
+	SortColumn     key.Binding
+	SortReset      key.Binding
+	Widen          key.Binding
+	Narrow         key.Binding
+	Filter         key.Binding
+	Type           key.Binding
+	Stale          key.Binding
+	Server         key.Binding
+	Columns        key.Binding
+	CopyRow        key.Binding
+	CopyIP         key.Binding
+	CopyMAC        key.Binding
+	CopySSH        key.Binding
+	Export         key.Binding
+	ExportMarkdown key.Binding
+	QuitTSV        key.Binding
+	Refresh        key.Binding
+	Pause          key.Binding
+	Help           key.Binding
+	Quit           key.Binding
+}
+
+var keys = keyMap{
+	Table:          tableKeyMap(),
+	SortPrev:       key.NewBinding(key.WithKeys("left"), key.WithHelp("←", "sort by previous column")),
+	SortNext:       key.NewBinding(key.WithKeys("right"), key.WithHelp("→", "sort by next column")),
+	SortOrder:      key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "toggle sort order")),
+	SortSecondary:  key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "change tie-break column")),
+	SortColumn:     key.NewBinding(key.WithKeys("1", "2", "3", "4", "5", "6", "7", "8", "9"), key.WithHelp("1-9", "sort by Nth column")),
+	SortReset:      key.NewBinding(key.WithKeys("0"), key.WithHelp("0", "reset sort")),
+	Widen:          key.NewBinding(key.WithKeys("+", "="), key.WithHelp("+", "widen sort column")),
+	Narrow:         key.NewBinding(key.WithKeys("-"), key.WithHelp("-", "narrow sort column")),
+	Filter:         key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "filter rows")),
+	Type:           key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "cycle static/dynamic")),
+	Stale:          key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "show stale leases only")),
+	Server:         key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "cycle DHCP server")),
+	Columns:        key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "show/hide a column")),
+	CopyRow:        key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "copy row")),
+	CopyIP:         key.NewBinding(key.WithKeys("i"), key.WithHelp("i", "copy IP")),
+	CopyMAC:        key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "copy MAC")),
+	CopySSH:        key.NewBinding(key.WithKeys("h"), key.WithHelp("h", "copy ssh command")),
+	Export:         key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "export CSV")),
+	ExportMarkdown: key.NewBinding(key.WithKeys("M"), key.WithHelp("M", "export Markdown")),
+	QuitTSV:        key.NewBinding(key.WithKeys("T"), key.WithHelp("T", "quit and save rows as TSV")),
+	Refresh:        key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "refresh")),
+	Pause:          key.NewBinding(key.WithKeys("p"), key.WithHelp("p", "pause auto-refresh")),
+	Help:           key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "toggle help")),
+	Quit:           key.NewBinding(key.WithKeys("q", "esc", "ctrl+c"), key.WithHelp("q/esc", "quit")),
+}
+
+// tableKeyMap is the table's default navigation without space, which
+// toggles the sort order, and d, which removes a lease.
+func tableKeyMap() table.KeyMap {
+	km := table.DefaultKeyMap()
+	km.PageDown = key.NewBinding(key.WithKeys("f", "pgdown"), key.WithHelp("f/pgdn", "page down"))
+	km.HalfPageDown = key.NewBinding(key.WithKeys("ctrl+d"), key.WithHelp("ctrl+d", "½ page down"))
+	return km
+}
+
+// ShortHelp implements help.KeyMap
+func (k keyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Help, k.Quit}
+}
+
+// FullHelp implements help.KeyMap
+func (k keyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Table.LineUp, k.Table.LineDown, k.Table.PageUp, k.Table.PageDown,
+			k.Table.HalfPageUp, k.Table.HalfPageDown, k.Table.GotoTop, k.Table.GotoBottom},
+		{k.SortPrev, k.SortNext, k.SortColumn, k.SortOrder, k.SortSecondary, k.SortReset, k.Widen, k.Narrow, k.Filter, k.Type, k.Stale, k.Server, k.Columns},
+		{k.CopyRow, k.CopyIP, k.CopyMAC, k.CopySSH, k.Export, k.ExportMarkdown, k.QuitTSV, k.Refresh, k.Pause, k.Help, k.Quit},
+	}
+}
+
+// tickMsg triggers an automatic refresh in watch mode
+type tickMsg time.Time
+
+// tick schedules the next watch-mode refresh
+func (m Model) tick() tea.Cmd {
+	return tea.Tick(m.watchInterval, func(t time.Time) tea.Msg {
+		return tickMsg(t)
+	})
+}
+
+// rowsMsg carries the result of a background refresh
+type rowsMsg struct {
+	rows []table.Row
+	err  error
+}
+
+// refresh re-fetches the rows from the router in the background
+func (m Model) refresh() tea.Cmd {
+	fetch := m.fetch
+	return func() tea.Msg {
+		rows, err := fetch()
+		return rowsMsg{rows: rows, err: err}
+	}
+}
+
+// Init implements tea.Model
+func (m Model) Init() tea.Cmd {
+	if m.watchInterval > 0 {
+		return m.tick()
+	}
+	return nil
+}
+
+// Update implements tea.Model
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		// While the filter input has focus it receives all keys
+		if m.filtering {
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "esc":
+				m.clearFilter()
+				return m, nil
+			case "enter":
+				m.filtering = false
+				m.filter.Blur()
+				m.table.Focus()
+				return m, nil
+			}
+			m.filter, cmd = m.filter.Update(msg)
+			m.updateRows()
+			return m, cmd
+		}
+
+		// A pending action waits for y/n on each of its prompts
+		if m.pendingAction != nil {
+			return m.confirmAction(msg.String() == "y")
+		}
+		for i := range m.actions {
+			if key.Matches(msg, m.actions[i].Binding) {
+				m.startAction(&m.actions[i])
+				return m, nil
+			}
+		}
+
+		// After "c" the next key picks the column to show or hide
+		if m.columnMenu {
+			m.columnMenu = false
+			m.status = ""
+			if n, err := strconv.Atoi(msg.String()); err == nil {
+				m.toggleColumn(n - 1)
+			}
+			return m, nil
+		}
+
+		switch {
+		case key.Matches(msg, keys.Help):
+			m.showHelp = !m.showHelp
+			return m, nil
+		case key.Matches(msg, keys.Quit):
+			// Escape clears an active filter before quitting
+			if msg.String() == "esc" && m.filter.Value() != "" {
+				m.clearFilter()
+				return m, nil
+			}
+			return m, tea.Quit
+		case key.Matches(msg, keys.QuitTSV):
+			m.tsvOnQuit = true
+			return m, tea.Quit
+		case key.Matches(msg, keys.Type):
+			// Cycle all -> static -> dynamic
+			switch m.typeFilter {
+			case "":
+				m.typeFilter = "static"
+			case "static":
+				m.typeFilter = "dynamic"
+			default:
+				m.typeFilter = ""
+			}
+			m.updateRows()
+		case key.Matches(msg, keys.Stale):
+			if m.ColumnIndex("Status") >= 0 && m.ColumnIndex("Expires") >= 0 {
+				m.staleOnly = !m.staleOnly
+				m.updateRows()
+			}
+		case key.Matches(msg, keys.Server):
+			m.serverFilter = m.nextServer()
+			m.updateRows()
+		case key.Matches(msg, keys.CopyRow):
+			if row := m.table.SelectedRow(); row != nil {
+				m.status = copyToClipboard("row", strings.Join(row, "\t"))
+			}
+		case key.Matches(msg, keys.CopySSH):
+			if row, col := m.selectedRow(), m.ColumnIndex("IP"); row != nil && col >= 0 && row[col] != "" {
+				m.status = copyToClipboard("ssh command", sshCommand(row[col]))
+			}
+		case key.Matches(msg, keys.CopyIP, keys.CopyMAC):
+			title := map[string]string{"i": "IP", "m": "MAC"}[msg.String()]
+			if row, col := m.selectedRow(), m.ColumnIndex(title); row != nil && col >= 0 {
+				m.status = copyToClipboard(title, row[col])
+			}
+		case key.Matches(msg, keys.Columns):
+			m.columnMenu = true
+			m.status = m.columnMenuPrompt()
+		case key.Matches(msg, keys.Filter):
+			m.filtering = true
+			m.table.Blur()
+			m.resize()
+			return m, m.filter.Focus()
+		case key.Matches(msg, keys.SortNext):
+			m.sortColumn = m.nextVisibleColumn(m.sortColumn, 1)
+			m.updateRows()
+		case key.Matches(msg, keys.SortPrev):
+			m.sortColumn = m.nextVisibleColumn(m.sortColumn, -1)
+			m.updateRows()
+		case key.Matches(msg, keys.SortSecondary):
+			m.secondaryColumn = m.nextVisibleColumn(m.secondaryColumn, 1)
+			m.updateRows()
+		case key.Matches(msg, keys.SortOrder):
+			m.sortAscending = !m.sortAscending
+			m.updateRows()
+		case key.Matches(msg, keys.SortColumn):
+			// Picking the current sort column again flips the order
+			n, _ := strconv.Atoi(msg.String())
+			if col := m.visibleColumn(n - 1); col == m.sortColumn {
+				m.sortAscending = !m.sortAscending
+			} else if col >= 0 {
+				m.sortColumn, m.sortAscending = col, true
+			}
+			m.updateRows()
+		case key.Matches(msg, keys.SortReset):
+			m.resetSort()
+			m.updateRows()
+		case key.Matches(msg, keys.Widen, keys.Narrow):
+			step := columnWidthStep
+			if key.Matches(msg, keys.Narrow) {
+				step = -step
+			}
+			// Start from the width as drawn, which fitColumns may have
+			// scaled, so each press changes what's on screen by one step
+			col := &m.columns[m.sortColumn]
+			width := col.Width
+			for _, shown := range m.table.Columns() {
+				if shown.Title == col.Title {
+					width = shown.Width
+				}
+			}
+			col.Width = max(width+step, minColumnWidth)
+			columnWidths[m.name+"/"+col.Title] = col.Width
+			m.updateRows()
+		case key.Matches(msg, keys.Export, keys.ExportMarkdown):
+			export := m.exportCSV
+			if key.Matches(msg, keys.ExportMarkdown) {
+				export = m.exportMarkdown
+			}
+			if path, err := export(); err != nil {
+				m.status = fmt.Sprintf("Export failed: %v", err)
+			} else {
+				m.status = fmt.Sprintf("Exported %d rows to %s", len(m.table.Rows()), path)
+			}
+		case key.Matches(msg, keys.Refresh):
+			if !m.refreshing {
+				m.refreshing = true
+				m.status = "Refreshing..."
+				return m, m.refresh()
+			}
+		case key.Matches(msg, keys.Pause):
+			if m.watchInterval > 0 {
+				m.watchPaused = !m.watchPaused
+				if m.watchPaused {
+					m.status = "Auto-refresh paused"
+				} else {
+					m.status = fmt.Sprintf("Auto-refresh resumed (every %v)", m.watchInterval)
+				}
+			}
+		}
+	case StatusMsg:
+		m.status = string(msg)
+		return m, nil
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.help.Width = msg.Width
+		m.updateRows()
+		return m, nil
+	case tickMsg:
+		// Skip this tick if paused or the previous refresh is still running
+		if m.watchPaused || m.refreshing {
+			return m, m.tick()
+		}
+		m.refreshing = true
+		m.status = "Refreshing..."
+		return m, tea.Batch(m.tick(), m.refresh())
+	case rowsMsg:
+		m.refreshing = false
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Refresh failed: %v", msg.err)
+			return m, nil
+		}
+		m.setRows(msg.rows)
+		if m.keepStatus {
+			m.keepStatus = false
+		} else {
+			m.status = fmt.Sprintf("Refreshed %d rows at %s", len(msg.rows), time.Now().Format("15:04:05"))
+		}
+		return m, nil
+	case actionMsg:
+		switch {
+		case msg.err != nil && msg.output != "":
+			m.status = fmt.Sprintf("Failed: %v: %s", msg.err, msg.output)
+		case msg.err != nil:
+			m.status = fmt.Sprintf("Failed: %v", msg.err)
+		case msg.output != "":
+			// RouterOS reports many errors, such as "failure: ...", as
+			// output with a zero exit status
+			m.status = msg.output
+		default:
+			m.status = "Done"
+		}
+		if m.refreshing {
+			return m, nil
+		}
+		m.refreshing = true
+		m.keepStatus = true
+		return m, m.refresh()
+	}
+	m.table, cmd = m.table.Update(msg)
+	return m, cmd
+}
+
+// setRows replaces the full row set and re-applies the filter and sort
+func (m *Model) setRows(rows []table.Row) {
+	m.rows = rows
+	m.updateRows()
+}
+
+// updateRows shows the rows matching the current filter, in sorted order,
+// with hidden columns left out.
+func (m *Model) updateRows() {
+	query := strings.ToLower(m.filter.Value())
+	typeCol := m.ColumnIndex("Type")
+	serverCol := m.ColumnIndex("Server")
+
+	// A filter of CIDRs matches addresses in them rather than text
+	ipCol := m.ColumnIndex("IP")
+	querySubnets, err := routeros.ParseSubnets(query)
+	if err != nil || ipCol < 0 {
+		querySubnets = nil
+	}
+	var rows []table.Row
+	for _, row := range m.rows {
+		if m.typeFilter != "" && typeCol >= 0 && row[typeCol] != m.typeFilter {
+			continue
+		}
+		if m.staleOnly && m.staleness(row) == leaseCurrent {
+			continue
+		}
+		if m.serverFilter != "" && serverCol >= 0 && row[serverCol] != m.serverFilter {
+			continue
+		}
+		switch {
+		case querySubnets != nil:
+			if routeros.InSubnets(row[ipCol], querySubnets) {
+				rows = append(rows, row)
+			}
+		case query == "" || rowContains(row, query):
+			rows = append(rows, row)
+		}
+	}
+	m.sortRows(rows)
+	m.shown = rows
+
+	var columns []table.Column
+	var visible []int
+	for i, col := range m.columns {
+		if !m.hidden[col.Title] {
+			columns = append(columns, col)
+			visible = append(visible, i)
+		}
+	}
+	projected := make([]table.Row, len(rows))
+	for r, row := range rows {
+		cells := make(table.Row, len(visible))
+		for c, i := range visible {
+			cells[c] = row[i]
+		}
+		projected[r] = cells
+	}
+
+	// Clear the rows first so the table never renders old rows against
+	// a wider column set.
+	m.table.SetRows(nil)
+	m.table.SetColumns(fitColumns(columns, m.pinnedColumns(), m.width))
+	m.table.SetRows(projected)
+	m.resize()
+	m.rowStyles = m.rowColors()
+}
+
+// rowColors maps each displayed row, by index, to the color for its vendor,
+// or red and yellow for expired and expiring leases. Staleness applies with
+// or without a Vendor column; rows with neither are left out. It is nil when
+// coloring is off.
+func (m Model) rowColors() map[int]lipgloss.Style {
+	if !ColorEnabled() {
+		return nil
+	}
+	vendorCol := m.ColumnIndex("Vendor")
+	styles := make(map[int]lipgloss.Style, len(m.shown))
+	for r, row := range m.shown {
+		switch m.staleness(row) {
+		case leaseExpired:
+			styles[r] = expiredStyle
+		case leaseExpiring:
+			styles[r] = expiringStyle
+		default:
+			if vendorCol >= 0 {
+				styles[r] = vendorStyle(row[vendorCol])
+			}
+		}
+	}
+	return styles
+}
+
+// Staleness of a lease row, from its status and remaining time
+const (
+	leaseCurrent = iota
+	leaseExpiring
+	leaseExpired
+)
+
+var (
+	expiredStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	expiringStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+)
+
+// staleness classifies a full row of a table with Status and Expires
+// columns. Leases that aren't bound, because they expired or their client
+// never came back, count as expired; bound ones expiring within
+// ExpiryWarning as expiring.
+func (m Model) staleness(row table.Row) int {
+	statusCol, expiresCol := m.ColumnIndex("Status"), m.ColumnIndex("Expires")
+	if statusCol < 0 || expiresCol < 0 {
+		return leaseCurrent
+	}
+	switch row[statusCol] {
+	case "expired", "waiting":
+		return leaseExpired
+	}
+	if d, err := routeros.ParseDuration(row[expiresCol]); err == nil && d > 0 && d <= ExpiryWarning {
+		return leaseExpiring
+	}
+	return leaseCurrent
+}
+
+// renderedRow renders row the way the table draws an unselected row, with
+// trailing padding trimmed, so lines of the table view can be matched back
+// to their rows.
+func renderedRow(row table.Row, columns []table.Column) string {
+	cell := table.DefaultStyles().Cell
+	cells := make([]string, 0, len(columns))
+	for i, value := range row {
+		if i >= len(columns) || columns[i].Width <= 0 {
+			continue
+		}
+		style := lipgloss.NewStyle().Width(columns[i].Width).MaxWidth(columns[i].Width).Inline(true)
+		cells = append(cells, cell.Render(style.Render(runewidth.Truncate(value, columns[i].Width, "…"))))
+	}
+	return strings.TrimRight(lipgloss.JoinHorizontal(lipgloss.Top, cells...), " ")
+}
+
+// colorRows colors the rows of a rendered table view. The table has no
+// per-row styles and would count color codes in a cell as text when
+// truncating, so whole lines are colored after rendering instead, each
+// line matched to its row by its distance from the highlighted cursor row,
+// which keeps its highlight. In plain output,
+// where nothing is highlighted, the selected row is marked with ">".
+func (m Model) colorRows(view string) string {
+	if noColor && len(m.table.Rows()) > 0 {
+		selected := renderedRow(m.table.SelectedRow(), m.table.Columns())
+		lines := strings.Split(view, "\n")
+		for i, line := range lines {
+			if strings.TrimRight(line, " ") == selected && strings.HasPrefix(line, " ") {
+				lines[i] = ">" + line[1:]
+				break
+			}
+		}
+		return strings.Join(lines, "\n")
+	}
+	if m.rowStyles == nil || len(m.table.Rows()) == 0 {
+		return view
+	}
+
+	// Only the selected row carries escape codes below the header, and it
+	// is the cursor row, so the lines around it are numbered from there.
+	lines := strings.Split(view, "\n")
+	if len(lines) <= tableHeaderHeight {
+		return view
+	}
+	body := lines[tableHeaderHeight:]
+	selected := slices.IndexFunc(body, func(line string) bool { return strings.Contains(line, "\x1b[") })
+	if selected < 0 {
+		return view
+	}
+	for i, line := range body {
+		row := m.table.Cursor() + i - selected
+		if style, ok := m.rowStyles[row]; ok && i != selected && strings.TrimSpace(line) != "" {
+			body[i] = style.Render(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tableHeaderHeight is the number of lines the table header and its border
+// take up.
+const tableHeaderHeight = 2
+
+// footerHeight is reserved below the table for the status, summary and
+// help lines
+const footerHeight = 4
+
+// resize fits the table to the terminal height, or PageSize rows if
+// smaller, so the sort header and status line stay on screen while the rows
+// scroll.
+func (m *Model) resize() {
+	h := len(m.table.Rows()) + tableHeaderHeight
+	if PageSize > 0 {
+		h = min(h, PageSize+tableHeaderHeight)
+	}
+	if m.height > 0 {
+		available := m.height - strings.Count(m.headerView(), "\n") - footerHeight
+		h = min(h, available)
+	}
+	m.table.SetHeight(max(h, tableHeaderHeight+1))
+}
+
+// minColumnWidth is the narrowest a column is drawn, and columnWidthStep
+// how much + and - change the sort column's width
+const (
+	minColumnWidth  = 3
+	columnWidthStep = 2
+)
+
+// columnWidths keeps the widths set with + and - for the rest of the run,
+// keyed by table name and column title, e.g. "leases/Hostname".
+var columnWidths = make(map[string]int)
+
+// pinnedColumns returns the titles of this table's columns whose width was
+// set with + or -.
+func (m Model) pinnedColumns() map[string]bool {
+	pinned := make(map[string]bool)
+	for _, col := range m.columns {
+		if _, ok := columnWidths[m.name+"/"+col.Title]; ok {
+			pinned[col.Title] = true
+		}
+	}
+	return pinned
+}
+
+// fitColumns scales the column widths proportionally so the table fills
+// width terminal cells. Pinned columns keep their width and only the space
+// left after them is shared out. Cells wider than their column are
+// truncated with an ellipsis by the table. A width of 0 keeps the default
+// widths.
+func fitColumns(columns []table.Column, pinned map[string]bool, width int) []table.Column {
+	const cellPadding = 2 // table.DefaultStyles pads each cell by 1 on both sides
+	const minWidth = minColumnWidth
+
+	total, scaled := 0, 0
+	available := width - cellPadding*len(columns)
+	for _, col := range columns {
+		if pinned[col.Title] {
+			available -= col.Width
+			continue
+		}
+		total += col.Width
+		scaled++
+	}
+	if width == 0 || total == 0 || available < minWidth*scaled {
+		return columns
+	}
+
+	fitted := make([]table.Column, len(columns))
+	used, last := 0, -1
+	for i, col := range columns {
+		if !pinned[col.Title] {
+			col.Width = max(col.Width*available/total, minWidth)
+			used += col.Width
+			last = i
+		}
+		fitted[i] = col
+	}
+	// Hand the rounding remainder to the last scaled column
+	if used < available {
+		fitted[last].Width += available - used
+	}
+	return fitted
+}
+
+// nextServer returns the DHCP server after the current server filter, in
+// name order, or "" to show all servers again.
+func (m Model) nextServer() string {
+	col := m.ColumnIndex("Server")
+	if col < 0 {
+		return ""
+	}
+	seen := make(map[string]bool)
+	var servers []string
+	for _, row := range m.rows {
+		if name := row[col]; name != "" && !seen[name] {
+			seen[name] = true
+			servers = append(servers, name)
+		}
+	}
+	sort.Strings(servers)
+	for _, name := range servers {
+		if name > m.serverFilter {
+			return name
+		}
+	}
+	return ""
+}
+
+// startAction asks the first confirmation for action on the selected row
+func (m *Model) startAction(action *Action) {
+	row := m.selectedRow()
+	if row == nil {
+		return
+	}
+	prompts, err := action.Prompts(*m, row)
+	if err != nil {
+		m.status = err.Error()
+		return
+	}
+	m.pendingAction, m.pendingRow, m.pendingPrompts = action, row, prompts
+	m.status = prompts[0] + " [y/N]"
+}
+
+// confirmAction answers the current prompt, running the action in the
+// background once every prompt is confirmed.
+func (m Model) confirmAction(yes bool) (tea.Model, tea.Cmd) {
+	if !yes {
+		m.pendingAction, m.pendingRow, m.pendingPrompts = nil, nil, nil
+		m.status = "Cancelled"
+		return m, nil
+	}
+	if m.pendingPrompts = m.pendingPrompts[1:]; len(m.pendingPrompts) > 0 {
+		m.status = m.pendingPrompts[0] + " [y/N]"
+		return m, nil
+	}
+
+	action, row := m.pendingAction, m.pendingRow
+	m.pendingAction, m.pendingRow = nil, nil
+	m.status = "Running..."
+	return m, func() tea.Msg {
+		output, err := action.Run(m, row)
+		return actionMsg{output: output, err: err}
+	}
+}
+
+// selectedRow returns the full row, hidden columns included, under the
+// cursor, or nil if there is none.
+func (m Model) selectedRow() table.Row {
+	if i := m.table.Cursor(); i >= 0 && i < len(m.shown) {
+		return m.shown[i]
+	}
+	return nil
+}
+
+// toggleColumn shows or hides column i. The last visible column cannot be
+// hidden, and sorting moves off a column when it is hidden.
+func (m *Model) toggleColumn(i int) {
+	if i < 0 || i >= len(m.columns) {
+		return
+	}
+	title := m.columns[i].Title
+	if m.hidden[title] {
+		delete(m.hidden, title)
+	} else {
+		if len(m.hidden) == len(m.columns)-1 {
+			m.status = "Cannot hide the last visible column"
+			return
+		}
+		m.hidden[title] = true
+		if m.sortColumn == i {
+			m.sortColumn = m.nextVisibleColumn(i, 1)
+		}
+	}
+	m.updateRows()
+}
+
+// nextVisibleColumn returns the first visible column after i in direction
+// step (1 or -1), wrapping around.
+func (m Model) nextVisibleColumn(i, step int) int {
+	n := len(m.columns)
+	for range n {
+		i = (i + step + n) % n
+		if !m.hidden[m.columns[i].Title] {
+			break
+		}
+	}
+	return i
+}
+
+// columnMenuPrompt lists the columns by number with their visibility
+func (m Model) columnMenuPrompt() string {
+	var b strings.Builder
+	b.WriteString("Toggle column:")
+	for i, col := range m.columns {
+		mark := "x"
+		if m.hidden[col.Title] {
+			mark = " "
+		}
+		fmt.Fprintf(&b, " %d[%s]%s", i+1, mark, col.Title)
+	}
+	return b.String()
+}
+
+// visibleColumn returns the index in m.columns of the nth (0-based) visible
+// column, or -1 if there are fewer visible columns.
+func (m Model) visibleColumn(n int) int {
+	for i, col := range m.columns {
+		if m.hidden[col.Title] {
+			continue
+		}
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return -1
+}
+
+// resetSort restores the default order: ascending by the first visible
+// column (IP in the lease table), breaking ties by IP where the table has
+// one.
+func (m *Model) resetSort() {
+	m.sortColumn = m.visibleColumn(0)
+	m.sortAscending = true
+	m.secondaryColumn = m.ColumnIndex("IP")
+}
+
+// copyToClipboard copies value to the system clipboard and returns a status
+// message describing the outcome.
+func copyToClipboard(what, value string) string {
+	if clipboard.Unsupported {
+		return fmt.Sprintf("No clipboard available, %s: %s", what, value)
+	}
+	if err := clipboard.WriteAll(value); err != nil {
+		return fmt.Sprintf("Clipboard unavailable (%v), %s: %s", err, what, value)
+	}
+	return fmt.Sprintf("Copied %s to clipboard: %s", what, value)
+}
+
+// sshCommand returns the command to connect to the device at ip, as
+// SSHUser when set
+func sshCommand(ip string) string {
+	if SSHUser != "" {
+		return "ssh " + SSHUser + "@" + ip
+	}
+	return "ssh " + ip
+}
+
+// ColumnIndex returns the index of the column with the given title, or -1
+func (m Model) ColumnIndex(title string) int {
+	for i, col := range m.columns {
+		if col.Title == title {
+			return i
+		}
+	}
+	return -1
+}
+
+// clearFilter resets the filter and restores all rows
+func (m *Model) clearFilter() {
+	m.filtering = false
+	m.filter.Blur()
+	m.filter.SetValue("")
+	m.table.Focus()
+	m.updateRows()
+}
+
+// rowContains reports whether any cell contains the lowercase query
+func rowContains(row table.Row, query string) bool {
+	for _, cell := range row {
+		if strings.Contains(strings.ToLower(cell), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortRows orders full rows by the current sort column, breaking ties by
+// the secondary column in ascending order and then by MAC, so the order is
+// deterministic.
+func (m *Model) sortRows(rows []table.Row) {
+	title := m.columns[m.sortColumn].Title
+	macCol := m.ColumnIndex("MAC")
+	sort.SliceStable(rows, func(i, j int) bool {
+		c := CompareCells(title, rows[i][m.sortColumn], rows[j][m.sortColumn])
+		if !m.sortAscending {
+			c = -c
+		}
+		if sec := m.secondaryColumn; c == 0 && sec >= 0 && sec != m.sortColumn {
+			c = CompareCells(m.columns[sec].Title, rows[i][sec], rows[j][sec])
+		}
+
+		// Remaining ties go by MAC, then by every cell in turn, so rows
+		// keep their place across refreshes whatever order they arrive in
+		if c == 0 && macCol >= 0 {
+			c = strings.Compare(rows[i][macCol], rows[j][macCol])
+		}
+		if c == 0 {
+			c = slices.Compare(rows[i], rows[j])
+		}
+		return c < 0
+	})
+}
+
+// CompareCells orders two cell values of the named column
+func CompareCells(title, a, b string) int {
+	if title == "Expires" {
+		// Sort by remaining time; leases that never expire go last
+		da, _ := routeros.ParseDuration(strings.TrimPrefix(a, "-"))
+		db, _ := routeros.ParseDuration(strings.TrimPrefix(b, "-"))
+		if a == "-" {
+			da = time.Duration(math.MaxInt64)
+		}
+		if b == "-" {
+			db = time.Duration(math.MaxInt64)
+		}
+		return cmp.Compare(da, db)
+	}
+
+	// Addresses compare numerically so 10.0.0.2 sorts before 10.0.0.10
+	if ia, ok := parseAddrPort(a); ok {
+		if ib, ok := parseAddrPort(b); ok {
+			return ia.Compare(ib)
+		}
+	}
+
+	// Byte sizes and plain counters compare numerically
+	if na, ok := routeros.ParseBytes(a); ok {
+		if nb, ok := routeros.ParseBytes(b); ok {
+			return cmp.Compare(na, nb)
+		}
+	}
+	if na, err := strconv.ParseFloat(a, 64); err == nil {
+		if nb, err := strconv.ParseFloat(b, 64); err == nil {
+			return cmp.Compare(na, nb)
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// parseAddrPort parses an IP address, with or without a port as shown in
+// the connection tracking table. A bare address gets port 0.
+func parseAddrPort(s string) (netip.AddrPort, bool) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.AddrPortFrom(addr, 0), true
+	}
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap, true
+	}
+	return netip.AddrPort{}, false
+}
+
+// headerView renders the sort, type filter and filter lines above the table
+func (m Model) headerView() string {
+	sortIndicator := "↑"
+	if !m.sortAscending {
+		sortIndicator = "↓"
+	}
+
+	// Add sort indicator to current column header
+	sortBy := m.columns[m.sortColumn].Title + " " + sortIndicator
+	if sec := m.secondaryColumn; sec >= 0 && sec != m.sortColumn {
+		sortBy += ", then " + m.columns[sec].Title
+	}
+	header := fmt.Sprintf("\n%sSorting by %s (← → or 1-9 to change column, space to toggle order, s to change tie-break, 0 to reset)\n\n",
+		RouterHeader(), sortBy)
+
+	if m.typeFilter != "" {
+		header += fmt.Sprintf("Showing %s leases only (t to change)\n\n", m.typeFilter)
+	}
+	if m.staleOnly {
+		header += fmt.Sprintf("Showing expired leases and those expiring within %v only (x to change)\n\n", ExpiryWarning)
+	}
+	if m.serverFilter != "" {
+		header += fmt.Sprintf("Showing leases from server %s only (v to change)\n\n", m.serverFilter)
+	}
+	if m.note != "" {
+		header += m.note + "\n\n"
+	}
+	if m.filtering || m.filter.Value() != "" {
+		header += m.filter.View() + "\n\n"
+	}
+	return header
+}
+
+// View implements tea.Model
+func (m Model) View() string {
+	body := m.colorRows(m.table.View())
+	if m.showHelp {
+		groups := keys.FullHelp()
+		if len(m.actions) > 0 {
+			var actions []key.Binding
+			for _, a := range m.actions {
+				actions = append(actions, a.Binding)
+			}
+			groups = append(groups, actions)
+		}
+		body = HelpStyle().Render(m.help.FullHelpView(groups))
+	}
+	return m.headerView() + body + "\n\n" + m.status + "\n" + m.summary() + "\n" + m.position() + m.help.ShortHelpView(keys.ShortHelp())
+}
+
+// summary counts the displayed rows and their distinct vendors, e.g.
+// "Showing 12 of 340 leases · 5 vendors" while a filter hides some.
+func (m Model) summary() string {
+	text := fmt.Sprintf("%d %s", len(m.shown), m.noun)
+	if len(m.shown) != len(m.rows) {
+		text = fmt.Sprintf("Showing %d of %d %s", len(m.shown), len(m.rows), m.noun)
+	}
+
+	if col := m.ColumnIndex("Vendor"); col >= 0 {
+		vendors := make(map[string]bool)
+		for _, row := range m.shown {
+			if row[col] != "" {
+				vendors[row[col]] = true
+			}
+		}
+		text += fmt.Sprintf(" · %d vendors", len(vendors))
+		if len(vendors) == 1 {
+			text = strings.TrimSuffix(text, "s")
+		}
+	}
+	return text
+}
+
+// position describes the cursor row and page, e.g. "Row 12 of 340, page 2/15 · "
+func (m Model) position() string {
+	total := len(m.table.Rows())
+	if total == 0 {
+		return "No rows · "
+	}
+	pageSize := max(m.table.Height(), 1)
+	cursor := m.table.Cursor()
+	return fmt.Sprintf("Row %d of %d, page %d/%d · ",
+		cursor+1, total, cursor/pageSize+1, (total+pageSize-1)/pageSize)
+}
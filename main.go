@@ -2,77 +2,337 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"html/template"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/zalando/go-keyring"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 	"golang.org/x/term"
-)
 
-type DHCPLease struct {
-	Address    string
-	MacAddress string
-	Hostname   string
-	Vendor     string
-}
+	"github.com/ezeql/routeros-misc-tools/internal/config"
+	"github.com/ezeql/routeros-misc-tools/internal/macvendor"
+	"github.com/ezeql/routeros-misc-tools/internal/routeros"
+	"github.com/ezeql/routeros-misc-tools/internal/tui"
+)
 
-type MacVendor struct {
-	VendorDetails struct {
-		Company string `json:"company"`
-	} `json:"vendorDetails"`
+// Snapshot is the combined export written by -snapshot
+type Snapshot struct {
+	Timestamp  time.Time                `json:"timestamp"`
+	Router     string                   `json:"router"`
+	Leases     []routeros.DHCPLease     `json:"leases"`
+	ARP        []routeros.ARPEntry      `json:"arp"`
+	Interfaces []routeros.InterfaceStat `json:"interfaces"`
+	System     *routeros.SystemResource `json:"system,omitempty"`
+	Errors     []string                 `json:"errors,omitempty"`
 }
 
 type Credentials struct {
 	IP       string `json:"ip"`
 	Username string `json:"username"`
+	Port     int    `json:"port,omitempty"`
+	KeyPath  string `json:"key_path,omitempty"`
 }
 
 type RouterConnection struct {
+	mu      sync.Mutex // guards client across reconnects
 	client  *ssh.Client
 	config  *ssh.ClientConfig
 	address string
+	port    int
+	ctx     context.Context // cancels commands and reconnects, e.g. on SIGINT
+
+	// With -shell, the shared shell session, opened on first use
+	shellMu sync.Mutex // serializes commands on shell
+	shell   *routerShell
+
+	// Optional bastion the router is reached through
+	jumpClient  *ssh.Client
+	jumpConfig  *ssh.ClientConfig
+	jumpAddress string
+}
+
+// LeaseSource retrieves the raw DHCP leases from a router. RouterConnection
+// implements it over SSH, restLeaseSource over the RouterOS v7 REST API and
+// apiLeaseSource over the binary RouterOS API.
+type LeaseSource interface {
+	Leases() ([]routeros.DHCPLease, error)
+}
+
+// CommandRunner runs a RouterOS console command and returns its output.
+// RouterConnection implements it over SSH. The viewers only depend on it,
+// so canned output can stand in for a router.
+type CommandRunner interface {
+	Run(cmd string) ([]byte, error)
 }
 
-type VendorCache struct {
-	Vendors map[string]CacheEntry `json:"vendors"`
+type restLeaseSource struct {
+	baseURL  string
+	username string
+	password string
+	client   *http.Client
+	ctx      context.Context // cancels requests, e.g. on SIGINT
 }
 
-type CacheEntry struct {
-	Vendor    string    `json:"vendor"`
-	Timestamp time.Time `json:"timestamp"`
+// apiLeaseSource talks the binary RouterOS API over TLS (api-ssl). Replies
+// come as named attributes, so nothing is parsed from console output.
+type apiLeaseSource struct {
+	mu      sync.Mutex // one command at a time on the connection
+	conn    net.Conn
+	reader  *bufio.Reader
+	address string
+	ctx     context.Context // closes the connection, e.g. on SIGINT
 }
 
 const (
 	initialBackoff = 2 * time.Second
 	maxBackoff     = 60 * time.Second
+	defaultSSHPort = 22
+	defaultAPIPort = 8729 // api-ssl
+
+	maxReconnectAttempts = 5
+
+	// RouterOS commands sent by the viewers
+	leaseCommand        = "/ip dhcp-server lease print terse"
+	arpCommand          = "/ip arp print terse"
+	interfaceCommand    = "/interface print stats terse"
+	connectionCommand   = "/ip firewall connection print terse"
+	resourceCommand     = "/system resource print"
+	healthCommand       = "/system health print"
+	identityCommand     = "/system identity print"
+	dnsStaticCommand    = "/ip dns static print terse"
+	ipv6BindingCommand  = "/ipv6 dhcp-server binding print terse"
+	ipv6NeighborCommand = "/ipv6 neighbor print terse"
+	poolCommand         = "/ip pool print terse"
+	neighborCommand     = "/ip neighbor print terse"
+	dnsCacheCommand     = "/ip dns cache print terse"
+	bridgeHostCommand   = "/interface bridge host print terse"
+
+	// terse output has no .id, so list it per lease in the same key=value form
+	leaseIDCommand = `:foreach i in=[/ip dhcp-server lease find] do={:put ("id=" . $i . " address=" . [/ip dhcp-server lease get $i address] . " mac-address=" . [/ip dhcp-server lease get $i mac-address])}`
+
+	defaultConnectTimeout = 10 * time.Second
+
+	// Service name for passwords stored in the OS keyring
+	keyringService = "routeros-tools"
+
+	// Connection tracking tables can be huge; cap what the TUI has to render
+	defaultConnectionLimit = 500
+
+	// System dashboard refresh interval and warning thresholds
+	defaultDashboardInterval = 5 * time.Second
+	cpuLoadWarning           = 80   // percent
+	freeMemoryWarning        = 10   // percent of total
+	temperatureWarning       = 70.0 // degrees Celsius
+	minVoltageWarning        = 10.0 // volts
+
+	defaultPoolWarning   = 85 // percent of a pool in use
+	defaultExpiryWarning = 2 * time.Minute
+
+	// Reverse DNS for leases without a hostname
+	dnsLookupWorkers = 8
+	dnsLookupTimeout = 2 * time.Second
+)
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+var (
+	keyFlag        = flag.String("key", "", "path to an SSH private key (password auth is used when empty; none forgets a saved key)")
+	knownHostsFlag = flag.String("known-hosts", "", "path to the known_hosts file (default ~/.ssh/known_hosts)")
+	jsonFlag       = flag.Bool("json", false, "print the DHCP leases as JSON to stdout and exit")
+	plainFlag      = flag.Bool("plain", false, "print the DHCP leases as an aligned text table to stdout and exit")
+	ouiFlag        = flag.String("oui", "", "path to a local IEEE OUI database (oui.txt or oui.csv)")
+	pageSizeFlag   = flag.Int("page-size", 0, "rows per page in the table viewers (default: fit the terminal)")
+	watchFlag      = flag.Duration("watch", 0, "auto-refresh the lease table at this interval (e.g. 10s)")
+	transportFlag  = flag.String("transport", "ssh", "how to fetch DHCP leases: ssh, rest (RouterOS v7 REST API) or api (RouterOS API over TLS)")
+	insecureFlag   = flag.Bool("insecure", false, "skip TLS certificate verification for the REST and API transports")
+	keychainFlag   = flag.Bool("keychain", false, "read the password from, and offer to save it to, the OS keyring")
+
+	// State file locations, for services and shared or per-instance state
+	credentialsFileFlag = flag.String("credentials-file", "", "path to credentials.json (also ROUTEROS_CREDENTIALS_FILE; default: in the config directory)")
+	cacheFileFlag       = flag.String("cache-file", "", "path to the vendor cache, e.g. a shared read-only copy (also ROUTEROS_CACHE_FILE; default: in the config directory)")
+
+	// Non-interactive mode: any value given here is not prompted for
+	ipFlag            = flag.String("ip", "", "router IP or hostname")
+	userFlag          = flag.String("user", "", "router username")
+	portFlag          = flag.Int("port", 0, "router SSH port (default: saved port or 22)")
+	passwordFlag      = flag.String("password", "", "router password (visible in the process list; prefer -password-file or -password-stdin)")
+	passwordFileFlag  = flag.String("password-file", "", "read the router password from this file")
+	passwordStdinFlag = flag.Bool("password-stdin", false, "read the router password from the first line of stdin")
+	actionFlag        = flag.String("action", "", "run a single viewer and exit: leases, arp, ipv6, pools, neighbors, vendors, interfaces, connections, system or console")
+
+	timeoutFlag     = flag.Duration("timeout", defaultConnectTimeout, "router connection timeout (SSH and REST)")
+	httpTimeoutFlag = flag.Duration("http-timeout", macvendor.DefaultTimeout, "MAC vendor API request timeout")
+
+	verboseFlag = flag.Bool("v", false, "log commands, cache and API activity to stderr")
+	dryRunFlag  = flag.Bool("dry-run", false, "print the commands that would be sent and the target host, then exit")
+	jumpFlag    = flag.String("jump", "", "reach the router through an SSH bastion, as [user@]host[:port]")
+	jumpKeyFlag = flag.String("jump-key", "", "SSH private key for the bastion (default: same auth as the router)")
+	sshUserFlag = flag.String("ssh-user", "", "user for the ssh command copied with h in the table viewers (default: ssh's own default)")
+	shellFlag   = flag.Bool("shell", false, "send every command through one interactive shell session, for routers with strict session limits")
+
+	noColorFlag   = flag.Bool("no-color", false, "plain output without colors or box-drawing borders (also set by NO_COLOR, or when stdout isn't a terminal)")
+	noVendorFlag  = flag.Bool("no-vendor", false, "skip MAC vendor lookups and leave the Vendor column blank")
+	vendorTTLFlag = flag.Duration("vendor-ttl", macvendor.DefaultTTL, "how long cached vendor lookups are reused")
+	vendorAPIFlag = flag.String("vendor-api", macvendor.DefaultAPIURL, "MAC vendor lookup URL template; %s is replaced with the OUI")
+
+	poolWarningFlag   = flag.Int("pool-warning", defaultPoolWarning, "highlight DHCP pools at or above this utilization percentage")
+	expiryWarningFlag = flag.Duration("expiry-warning", defaultExpiryWarning, "highlight leases expiring within this duration")
+
+	versionFlag     = flag.Bool("version", false, "print the version and build information and exit")
+	testFlag        = flag.Bool("test", false, "connect, print the router identity and RouterOS version, and exit")
+	anonymizeFlag   = flag.Bool("anonymize", false, "replace MAC addresses and hostnames with stable pseudonyms in all output, for sharing captures")
+	maskIPsFlag     = flag.Bool("mask-ips", false, "zero the host part of lease and ARP addresses (IPv4 past /24, IPv6 past /64) in all output")
+	fieldsFlag      = flag.String("fields", "", "comma-separated lease columns to show and export, in order, e.g. ip,mac,hostname")
+	subnetFlag      = flag.String("subnet", "", "only show leases in these comma-separated CIDRs, e.g. 192.168.10.0/24,10.0.0.0/8")
+	loadFlag        = flag.String("load", "", "browse leases from a -json, -snapshot or CSV export instead of connecting to a router")
+	exportCacheFlag = flag.String("export-cache", "", "write the vendor cache to this file (- for stdout) for -import-cache on another machine, and exit")
+	importCacheFlag = flag.String("import-cache", "", "merge a file written by -export-cache into the vendor cache, newest entry per OUI winning, and exit")
+	diffFlag        = flag.Bool("diff", false, "compare two lease files given as arguments (old new) and exit 1 if they differ")
+	metricsFlag     = flag.Bool("metrics", false, "print lease and pool metrics in Prometheus text format to stdout and exit")
+	unresolvedFlag  = flag.String("unresolved", "", "list the OUIs whose vendor couldn't be resolved, with device counts, to this file (- for stdout) and exit")
+	htmlFlag        = flag.String("html", "", "write the DHCP leases to this file as a sortable HTML report and exit")
+	snapshotFlag    = flag.String("snapshot", "", "write leases, ARP, interfaces and system info to this JSON file and exit")
+)
+
+// viewerCommands lists the commands each -action, and -test, sends in order
+var viewerCommands = []struct {
+	action   string
+	commands []string
+}{
+	{"leases", []string{leaseCommand, leaseIDCommand, bridgeHostCommand, dnsStaticCommand, dnsCacheCommand}},
+	{"arp", []string{arpCommand, leaseCommand, leaseIDCommand}},
+	{"ipv6", []string{ipv6BindingCommand, ipv6NeighborCommand}},
+	{"pools", []string{poolCommand, leaseCommand, leaseIDCommand}},
+	{"neighbors", []string{neighborCommand}},
+	{"vendors", []string{leaseCommand, leaseIDCommand, bridgeHostCommand, dnsStaticCommand, dnsCacheCommand}},
+	{"interfaces", []string{interfaceCommand}},
+	{"connections", []string{connectionCommand}},
+	{"system", []string{resourceCommand, healthCommand}},
+	{"test", []string{identityCommand, resourceCommand}},
+}
+
+// dnsNames caches reverse DNS results for the run, keyed by IP. Failed
+// lookups are cached as "" so they aren't retried on every refresh.
+var (
+	dnsNamesMu sync.Mutex
+	dnsNames   = make(map[string]string)
 )
 
+// stdin is shared so buffered input isn't lost between reads when it's piped
+var stdin = bufio.NewReader(os.Stdin)
+
 func readInput(prompt string) string {
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print(prompt)
-	text, _ := reader.ReadString('\n')
+	fmt.Fprint(os.Stderr, prompt)
+	text, _ := stdin.ReadString('\n')
 	return strings.TrimSpace(text)
 }
 
 func readPassword(prompt string) string {
-	fmt.Print(prompt)
+	fmt.Fprint(os.Stderr, prompt)
 	password, _ := term.ReadPassword(int(os.Stdin.Fd()))
-	fmt.Println() // Add a newline after password input
+	fmt.Fprintln(os.Stderr) // Add a newline after password input
 	return string(password)
 }
 
+// Config holds defaults from config.json. Every key is optional and
+// command-line flags take precedence.
+type Config struct {
+	Timeout     string `json:"timeout,omitempty"`
+	HTTPTimeout string `json:"http_timeout,omitempty"`
+	VendorTTL   string `json:"vendor_ttl,omitempty"`
+	VendorAPI   string `json:"vendor_api,omitempty"`
+	Watch       string `json:"watch,omitempty"`
+	Transport   string `json:"transport,omitempty"`
+}
+
+// applyConfigFile sets flags not given on the command line from
+// config.json in the config directory. A missing file is not an error.
+func applyConfigFile() error {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil
+	}
+	path := filepath.Join(dir, "config.json")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	for _, setting := range []struct{ flag, value string }{
+		{"timeout", cfg.Timeout},
+		{"http-timeout", cfg.HTTPTimeout},
+		{"vendor-ttl", cfg.VendorTTL},
+		{"vendor-api", cfg.VendorAPI},
+		{"watch", cfg.Watch},
+		{"transport", cfg.Transport},
+	} {
+		if setting.value == "" || explicit[setting.flag] {
+			continue
+		}
+		if err := flag.Set(setting.flag, setting.value); err != nil {
+			return fmt.Errorf("%s: %s: %v", path, setting.flag, err)
+		}
+	}
+	slog.Debug("loaded config file", "path", path)
+	return nil
+}
+
 func loadCredentials() (Credentials, error) {
 	var creds Credentials
-	data, err := os.ReadFile("credentials.json")
+	data, err := config.ReadFile("credentials.json")
 	if err != nil {
 		return creds, err
 	}
@@ -80,381 +340,3810 @@ func loadCredentials() (Credentials, error) {
 	return creds, err
 }
 
+// printVersion writes the version, Go version and platform, plus the VCS
+// revision when the binary was built from a checkout.
+func printVersion(w io.Writer) {
+	v := version
+	info, ok := debug.ReadBuildInfo()
+	if v == "dev" && ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		v = info.Main.Version // go install module@version
+	}
+	fmt.Fprintf(w, "routeros-misc-tools %s\n", v)
+	fmt.Fprintf(w, "%s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if !ok {
+		return
+	}
+
+	settings := make(map[string]string)
+	for _, s := range info.Settings {
+		settings[s.Key] = s.Value
+	}
+	if revision := settings["vcs.revision"]; revision != "" {
+		if settings["vcs.modified"] == "true" {
+			revision += " (modified)"
+		}
+		if t := settings["vcs.time"]; t != "" {
+			revision += ", committed " + t
+		}
+		fmt.Fprintf(w, "revision %s\n", revision)
+	}
+}
+
 func saveCredentials(creds Credentials) error {
 	data, err := json.MarshalIndent(creds, "", "    ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile("credentials.json", data, 0600)
+	return config.WriteFile("credentials.json", data)
 }
 
 func main() {
 	var router *RouterConnection
+	var source LeaseSource
 	var err error
 
-	// Initial connection
-	router, err = connectToRouter()
-	if err != nil {
-		fmt.Printf("Error connecting to router: %v\n", err)
+	flag.BoolVar(verboseFlag, "debug", false, "alias for -v")
+	flag.Parse()
+
+	if *versionFlag {
+		printVersion(os.Stdout)
 		return
 	}
-	defer router.client.Close()
-
-	for {
-		fmt.Println("\nMikroTik Router Utilities")
-		fmt.Println("------------------------")
-		fmt.Println("1. DHCP Lease Viewer")
-		fmt.Println("2. Exit")
-		fmt.Print("\nSelect an option: ")
 
-		var choice string
-		if _, err := fmt.Scanln(&choice); err != nil {
-			fmt.Println("Error reading input. Please try again.")
-			continue
-		}
+	// Quiet unless asked; logs go to stderr so they can be redirected away
+	// from the TUI
+	logOutput := io.Discard
+	if *verboseFlag {
+		logOutput = os.Stderr
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(logOutput, &slog.HandlerOptions{Level: slog.LevelDebug})))
 
-		switch choice {
-		case "1":
-			viewDHCPLeases(router)
-		case "2":
-			fmt.Println("Goodbye!")
-			return
-		default:
-			fmt.Println("Invalid option. Please try again.")
-		}
+	if err := applyConfigFile(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading config file: %v\n", err)
+		os.Exit(1)
 	}
-}
 
-func connectToRouter() (*RouterConnection, error) {
-	// Try to load saved credentials
-	savedCreds, _ := loadCredentials()
+	tui.SetupColor(*noColorFlag)
 
-	// Get router IP
-	var routerIP string
-	if savedCreds.IP != "" {
-		routerIP = readInput(fmt.Sprintf("Router IP [%s]: ", savedCreds.IP))
-		if routerIP == "" {
-			routerIP = savedCreds.IP
-		}
-	} else {
-		routerIP = readInput("Router IP: ")
+	if *timeoutFlag <= 0 || *httpTimeoutFlag <= 0 || *vendorTTLFlag <= 0 {
+		fmt.Fprintln(os.Stderr, "Timeouts must be positive durations, e.g. -timeout 30s")
+		os.Exit(1)
+	}
+	if strings.Count(*vendorAPIFlag, "%s") != 1 {
+		fmt.Fprintf(os.Stderr, "The -vendor-api template must contain exactly one %%s for the OUI\n")
+		os.Exit(1)
+	}
+	if *pageSizeFlag < 0 {
+		fmt.Fprintln(os.Stderr, "-page-size must not be negative")
+		os.Exit(1)
+	}
+	if *expiryWarningFlag <= 0 {
+		fmt.Fprintln(os.Stderr, "-expiry-warning must be a positive duration, e.g. -expiry-warning 5m")
+		os.Exit(1)
 	}
+	if *poolWarningFlag < 0 || *poolWarningFlag > 100 {
+		fmt.Fprintln(os.Stderr, "-pool-warning must be a percentage between 0 and 100")
+		os.Exit(1)
+	}
+	configurePackages()
 
-	// Get username
-	var username string
-	if savedCreds.Username != "" {
-		username = readInput(fmt.Sprintf("Username [%s]: ", savedCreds.Username))
-		if username == "" {
-			username = savedCreds.Username
+	if *subnetFlag != "" {
+		if subnets, err = routeros.ParseSubnets(*subnetFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -subnet: %v\n", err)
+			os.Exit(1)
 		}
-	} else {
-		username = readInput("Username: ")
 	}
 
-	// Get password (never saved)
-	password := readPassword("Password: ")
-
-	// Save credentials
-	newCreds := Credentials{
-		IP:       routerIP,
-		Username: username,
-	}
-	if err := saveCredentials(newCreds); err != nil {
-		fmt.Printf("Error saving credentials: %v\n", err)
+	if *unresolvedFlag != "" && *noVendorFlag {
+		fmt.Fprintln(os.Stderr, "-unresolved needs vendor lookups, drop -no-vendor")
+		os.Exit(1)
 	}
 
-	config := &ssh.ClientConfig{
-		User: username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         10 * time.Second,
+	if *fieldsFlag != "" {
+		if selectedFields, err = parseFields(*fieldsFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -fields: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:22", routerIP), config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect: %v", err)
+	if *ouiFlag != "" {
+		macvendor.OUIDatabase, err = macvendor.LoadOUIDatabase(*ouiFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading OUI database: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	return &RouterConnection{
-		client:  client,
-		config:  config,
-		address: routerIP,
-	}, nil
-}
+	if *diffFlag {
+		os.Exit(runDiff(flag.Args()))
+	}
 
-func viewDHCPLeases(router *RouterConnection) {
-	session, err := router.client.NewSession()
-	if err != nil {
-		fmt.Printf("Error creating session: %v\n", err)
+	if *exportCacheFlag != "" {
+		n, err := macvendor.ExportCache(*exportCacheFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting vendor cache: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d vendor cache entries\n", n)
 		return
 	}
-	defer session.Close()
-
-	// Execute command to get leases with terse output
-	output, err := session.CombinedOutput("/ip dhcp-server lease print terse")
-	if err != nil {
-		fmt.Printf("Error executing command: %v\n", err)
+	if *importCacheFlag != "" {
+		added, updated, err := macvendor.ImportCache(*importCacheFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing vendor cache: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Imported vendor cache: %d new entries, %d updated\n", added, updated)
 		return
 	}
 
-	// Process output
-	leases := parseLeases(string(output))
-
-	// Get vendor information for each lease
-	for i := range leases {
-		leases[i].Vendor = getMacVendor(leases[i].MacAddress)
+	if macvendor.Overrides, err = macvendor.LoadOverrides(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading vendor overrides: %v\n", err)
+		os.Exit(1)
+	}
+	if err := tui.LoadVendorColors(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading vendor colors: %v\n", err)
+		os.Exit(1)
 	}
 
-	// Display table
-	printTable(leases)
-}
+	if *dryRunFlag {
+		printDryRun()
+		return
+	}
 
-func parseLeases(output string) []DHCPLease {
-	var leases []DHCPLease
-	lines := strings.Split(output, "\n")
+	// Ctrl+C or SIGTERM cancels in-flight commands, dials and HTTP requests,
+	// then shutdown closes the router session and exits
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, func() {
+		stop()
+		shutdown()
+	})
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+	// Initial connection, unless browsing a saved file
+	connectStart := time.Now()
+	if *loadFlag != "" {
+		if _, err := loadLeaseFile(*loadFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading leases: %v\n", err)
+			os.Exit(1)
 		}
-
-		lease := DHCPLease{}
-		parts := strings.Split(line, " ")
-
-		for _, part := range parts {
-			switch {
-			case strings.HasPrefix(part, "address="):
-				lease.Address = strings.TrimPrefix(part, "address=")
-			case strings.HasPrefix(part, "mac-address="):
-				lease.MacAddress = strings.TrimPrefix(part, "mac-address=")
-			case strings.HasPrefix(part, "host-name="):
-				lease.Hostname = strings.TrimPrefix(part, "host-name=")
+		source = fileLeaseSource{path: *loadFlag}
+	} else {
+		switch *transportFlag {
+		case "ssh":
+			router, err = connectToRouter(ctx)
+			source = router
+		case "rest":
+			source, err = connectREST(ctx)
+		case "api":
+			var api *apiLeaseSource
+			if api, err = connectAPI(ctx); err == nil {
+				defer api.Close()
+				source = api
 			}
+		default:
+			err = fmt.Errorf("unknown transport %q (expected ssh, rest or api)", *transportFlag)
 		}
-
-		if lease.Address != "" && lease.MacAddress != "" {
-			leases = append(leases, lease)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error connecting to router: %v\n", err)
+			if hint := connectHint(err); hint != "" {
+				fmt.Fprintln(os.Stderr, hint)
+			}
+			os.Exit(1)
 		}
 	}
-	return leases
-}
-
-func loadVendorCache() VendorCache {
-	var cache VendorCache
-	data, err := os.ReadFile("vendor_cache.json")
-	if err != nil {
-		return VendorCache{Vendors: make(map[string]CacheEntry)}
-	}
-	if err := json.Unmarshal(data, &cache); err != nil {
-		return VendorCache{Vendors: make(map[string]CacheEntry)}
+	if router != nil {
+		defer router.Close()
+		activeRouter.Store(router)
 	}
-	return cache
-}
 
-func saveVendorCache(cache VendorCache) error {
-	data, err := json.MarshalIndent(cache, "", "    ")
-	if err != nil {
-		return err
+	if *testFlag {
+		if err := testConnection(source, router, connectStart); err != nil {
+			fmt.Fprintf(os.Stderr, "Connection test failed: %v\n", err)
+			if router != nil {
+				router.Close()
+			}
+			os.Exit(1)
+		}
+		return
 	}
-	return os.WriteFile("vendor_cache.json", data, 0600)
-}
-
-func getMacVendor(mac string) string {
-	// Get first 3 octets for vendor lookup
-	oui := strings.ToUpper(strings.ReplaceAll(mac, ":", "")[:6])
-
-	cache := loadVendorCache()
 
-	// Check cache first
-	if entry, exists := cache.Vendors[oui]; exists {
-		// Cache entry valid for 30 days
-		if time.Since(entry.Timestamp) < 30*24*time.Hour {
-			return entry.Vendor
+	if *jsonFlag {
+		if err := printLeasesJSON(source); err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting leases: %v\n", err)
+			if router != nil {
+				router.Close()
+			}
+			os.Exit(1)
 		}
+		return
 	}
 
-	// If not in cache or expired, query API
-	vendor := queryMacVendorAPI(oui)
+	if *plainFlag {
+		if err := printLeasesPlain(os.Stdout, source); err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting leases: %v\n", err)
+			if router != nil {
+				router.Close()
+			}
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Only cache if we got a valid vendor response
-	if vendor != "Unknown" {
-		cache.Vendors[oui] = CacheEntry{
-			Vendor:    vendor,
-			Timestamp: time.Now(),
+	if *metricsFlag {
+		if err := printMetrics(os.Stdout, source, router); err != nil {
+			fmt.Fprintf(os.Stderr, "Error collecting metrics: %v\n", err)
+			if router != nil {
+				router.Close()
+			}
+			os.Exit(1)
 		}
-		if err := saveVendorCache(cache); err != nil {
-			fmt.Printf("Warning: Failed to save vendor cache: %v\n", err)
+		return
+	}
+
+	if *unresolvedFlag != "" {
+		if err := writeUnresolvedReport(*unresolvedFlag, source); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing unresolved vendor report: %v\n", err)
+			if router != nil {
+				router.Close()
+			}
+			os.Exit(1)
 		}
+		return
 	}
 
-	return vendor
-}
+	if *htmlFlag != "" {
+		if err := writeHTMLReport(*htmlFlag, source, router); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing HTML report: %v\n", err)
+			if router != nil {
+				router.Close()
+			}
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "HTML report written to %s\n", *htmlFlag)
+		return
+	}
 
-func queryMacVendorAPI(oui string) string {
-	backoff := initialBackoff
-	maxRetries := 3
+	if *snapshotFlag != "" {
+		if err := writeSnapshot(*snapshotFlag, source, router); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing snapshot: %v\n", err)
+			if router != nil {
+				router.Close()
+			}
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Snapshot written to %s\n", *snapshotFlag)
+		return
+	}
 
-	for retry := 0; retry < maxRetries; retry++ {
-		url := fmt.Sprintf("https://api.macvendors.com/%s", oui)
-		client := &http.Client{Timeout: 5 * time.Second}
+	// Name the router in every viewer header so it's clear which device
+	// is being looked at
+	tui.RouterLabel = sourceAddress(source, router)
+	if router != nil {
+		if info, err := fetchRouterInfo(router); err != nil {
+			slog.Debug("failed to fetch router identity", "error", err)
+		} else {
+			tui.RouterLabel = info.label(router.address)
+		}
+	}
 
-		resp, err := client.Get(url)
-		if err != nil {
-			return "Unknown"
+	// The remaining viewers need the RouterOS CLI, so only work over SSH
+	sshOnly := func(view func(CommandRunner)) {
+		if router == nil {
+			fmt.Println("This viewer requires the SSH transport.")
+			return
 		}
-		defer resp.Body.Close()
+		view(router)
+	}
 
-		if resp.StatusCode == http.StatusTooManyRequests {
-			if retry < maxRetries-1 { // Don't sleep on last retry
-				fmt.Printf("Rate limit reached, waiting %v before retry...\n", backoff)
-				time.Sleep(backoff)
-				backoff *= 2 // Exponential backoff
-				if backoff > maxBackoff {
-					backoff = maxBackoff
-				}
-				continue
+	if *actionFlag != "" {
+		actions := map[string]func(){
+			"leases":      func() { viewDHCPLeases(source) },
+			"arp":         func() { sshOnly(viewARP) },
+			"ipv6":        func() { sshOnly(viewIPv6) },
+			"pools":       func() { sshOnly(viewPoolUsage) },
+			"neighbors":   func() { sshOnly(viewNeighbors) },
+			"vendors":     func() { viewVendors(source) },
+			"console":     func() { sshOnly(runConsole) },
+			"interfaces":  func() { sshOnly(viewInterfaceStats) },
+			"connections": func() { sshOnly(viewConnections) },
+			"system":      func() { sshOnly(viewSystemResources) },
+		}
+		action, ok := actions[*actionFlag]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Unknown action %q\n", *actionFlag)
+			if router != nil {
+				router.Close()
 			}
-			return "Rate Limited"
+			os.Exit(1)
 		}
+		action()
+		return
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			return "Unknown"
+	// Without a terminal there is no one to pick from the menu, so print
+	// the leases the way -plain does rather than wait on input
+	if !tui.Interactive() {
+		fmt.Fprintln(os.Stderr, "Not running in a terminal, printing DHCP leases (use -action or -json to choose the output)")
+		if err := printLeasesPlain(os.Stdout, source); err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting leases: %v\n", err)
+			if router != nil {
+				router.Close()
+			}
+			os.Exit(1)
 		}
+		return
+	}
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "Unknown"
-		}
+	for ctx.Err() == nil {
+		fmt.Println("\nMikroTik Router Utilities")
+		fmt.Println("------------------------")
+		fmt.Println("1. DHCP Lease Viewer")
+		fmt.Println("2. ARP Table Viewer")
+		fmt.Println("3. Interface Statistics")
+		fmt.Println("4. Connection Tracking")
+		fmt.Println("5. System Resources")
+		fmt.Println("6. IPv6 Hosts")
+		fmt.Println("7. Pool Utilization")
+		fmt.Println("8. Neighbors")
+		fmt.Println("9. Vendors")
+		fmt.Println("10. RouterOS Console")
+		fmt.Println("11. Exit")
+		fmt.Print("\nSelect an option: ")
 
-		var vendor MacVendor
-		if err := json.Unmarshal(body, &vendor); err != nil {
-			return string(body) // Return plain text if not JSON
+		var choice string
+		if _, err := fmt.Scanln(&choice); errors.Is(err, io.EOF) {
+			return
+		} else if err != nil {
+			fmt.Println("Error reading input. Please try again.")
+			continue
 		}
 
-		return vendor.VendorDetails.Company
+		switch choice {
+		case "1":
+			viewDHCPLeases(source)
+		case "2":
+			sshOnly(viewARP)
+		case "3":
+			sshOnly(viewInterfaceStats)
+		case "4":
+			sshOnly(viewConnections)
+		case "5":
+			sshOnly(viewSystemResources)
+		case "6":
+			sshOnly(viewIPv6)
+		case "7":
+			sshOnly(viewPoolUsage)
+		case "8":
+			sshOnly(viewNeighbors)
+		case "9":
+			viewVendors(source)
+		case "10":
+			sshOnly(runConsole)
+		case "11":
+			fmt.Println("Goodbye!")
+			return
+		default:
+			fmt.Println("Invalid option. Please try again.")
+		}
 	}
+}
+
+// activeRouter is set once the router is connected
+var activeRouter atomic.Pointer[RouterConnection]
+
+// configurePackages hands the validated flags to the config, vendor lookup
+// and table viewer packages, and routes their messages through the TUI
+// status line while one is running.
+func configurePackages() {
+	config.SetPath("credentials.json", flagOrEnv(*credentialsFileFlag, "ROUTEROS_CREDENTIALS_FILE"))
+	config.SetPath("vendor_cache.json", flagOrEnv(*cacheFileFlag, "ROUTEROS_CACHE_FILE"))
 
-	return "Rate Limited"
+	macvendor.APIURL = *vendorAPIFlag
+	macvendor.TTL = *vendorTTLFlag
+	macvendor.Timeout = *httpTimeoutFlag
+	macvendor.ReportStatus = func(msg string) { tui.ReportStatus(msg) }
+	macvendor.ReportProgress = func(done, total int) { tui.ReportProgress(done, total) }
+
+	tui.WatchInterval = *watchFlag
+	tui.PageSize = *pageSizeFlag
+	tui.ExpiryWarning = *expiryWarningFlag
+	tui.SSHUser = *sshUserFlag
 }
 
-func printTable(leases []DHCPLease) {
-	// Define table style
-	columns := []table.Column{
-		{Title: "IP", Width: 15},
-		{Title: "MAC", Width: 17},
-		{Title: "Hostname", Width: 20},
-		{Title: "Vendor", Width: 30},
+// shutdown runs on SIGINT or SIGTERM once outstanding work is cancelled. It
+// closes the router session right away, since deferred calls don't run on
+// the os.Exit paths and an abandoned session counts against the router's
+// session limit until it times out. A running TUI quits on the signal by
+// itself and the menu then returns, so the process is only exited here
+// when no TUI is running.
+func shutdown() {
+	if router := activeRouter.Load(); router != nil {
+		router.Close()
+	}
+	if tui.Active() {
+		return
 	}
+	fmt.Fprintln(os.Stderr, "\nInterrupted")
+	os.Exit(130)
+}
 
-	// Convert leases to rows
-	var rows []table.Row
-	for _, lease := range leases {
-		rows = append(rows, table.Row{
-			lease.Address,
-			lease.MacAddress,
-			lease.Hostname,
-			lease.Vendor,
-		})
+// printDryRun shows what the tool would send without connecting
+func printDryRun() {
+	if *loadFlag != "" {
+		fmt.Printf("Leases loaded from %s; nothing is sent to a router\n", *loadFlag)
+		return
 	}
 
-	// Create and style the table
-	t := table.New(
-		table.WithColumns(columns),
-		table.WithRows(rows),
-		table.WithFocused(true),
-		table.WithHeight(len(rows)),
-	)
+	host := flagOrEnv(*ipFlag, "ROUTEROS_IP")
+	if host == "" {
+		saved, _ := loadCredentials()
+		host = saved.IP
+	}
+	if host == "" {
+		host = "<prompted>"
+	}
 
-	s := table.DefaultStyles()
-	s.Header = s.Header.
-		BorderStyle(lipgloss.NormalBorder()).
-		BorderForeground(lipgloss.Color("240")).
-		BorderBottom(true).
-		Bold(true)
-	s.Selected = s.Selected.
-		Foreground(lipgloss.Color("229")).
-		Background(lipgloss.Color("57")).
-		Bold(false)
-	t.SetStyles(s)
-
-	// Initialize model with default sorting
-	m := Model{
-		table:         t,
-		sortColumn:    0,
-		sortAscending: true,
-	}
-	m.sortTable() // Initial sort
-
-	// Initialize bubbletea program
-	p := tea.NewProgram(m)
-	if _, err := p.Run(); err != nil {
-		fmt.Printf("Error running program: %v", err)
+	if *transportFlag == "rest" {
+		fmt.Printf("Target: https://%s (REST)\n", host)
+		fmt.Printf("  GET /rest/system/identity\n")
+		fmt.Printf("  GET /rest/ip/dhcp-server/lease\n")
+		return
+	}
+	if *transportFlag == "api" {
+		fmt.Printf("Target: %s (RouterOS API over TLS)\n", net.JoinHostPort(host, strconv.Itoa(cmp.Or(*portFlag, defaultAPIPort))))
+		fmt.Printf("  /login\n")
+		fmt.Printf("  /ip/dhcp-server/lease/print\n")
 		return
 	}
-}
 
-// Model represents the UI state
-type Model struct {
-	table         table.Model
-	sortColumn    int
-	sortAscending bool
-}
+	port := *portFlag
+	if port == 0 {
+		saved, _ := loadCredentials()
+		port = cmp.Or(saved.Port, defaultSSHPort)
+	}
+	fmt.Printf("Target: %s:%d (SSH)\n", host, port)
+	if *jumpFlag != "" {
+		fmt.Printf("Via jump host: %s\n", *jumpFlag)
+	}
 
-// Init implements tea.Model
-func (m Model) Init() tea.Cmd {
-	return nil
+	// Export modes only run some of the collectors
+	var actions []string
+	switch {
+	case *testFlag:
+		actions = []string{"test"}
+	case *jsonFlag || *plainFlag || *htmlFlag != "" || *unresolvedFlag != "":
+		actions = []string{"leases"}
+	case *metricsFlag:
+		actions = []string{"leases", "pools"}
+	case *snapshotFlag != "":
+		actions = []string{"leases", "arp", "interfaces", "system"}
+	case *actionFlag != "":
+		actions = []string{*actionFlag}
+	}
+	for _, viewer := range viewerCommands {
+		if actions != nil && !slices.Contains(actions, viewer.action) {
+			continue
+		}
+		fmt.Printf("%s:\n", viewer.action)
+		for _, cmd := range viewer.commands {
+			fmt.Printf("  %s\n", cmd)
+		}
+	}
 }
 
-// Update implements tea.Model
-func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var cmd tea.Cmd
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		switch msg.String() {
-		case "q", "ctrl+c", "esc":
-			return m, tea.Quit
-		case "right":
-			m.sortColumn = (m.sortColumn + 1) % 4
-			m.sortTable()
-		case "left":
-			m.sortColumn = (m.sortColumn - 1 + 4) % 4
-			m.sortTable()
-		case " ":
-			m.sortAscending = !m.sortAscending
-			m.sortTable()
-		}
-	}
-	m.table, cmd = m.table.Update(msg)
-	return m, cmd
-}
-
-func (m *Model) sortTable() {
-	rows := m.table.Rows()
-	sort.Slice(rows, func(i, j int) bool {
-		a := rows[i][m.sortColumn]
-		b := rows[j][m.sortColumn]
-		if m.sortAscending {
-			return a < b
-		}
-		return a > b
-	})
-	m.table.SetRows(rows)
+// maxAddressPrompts bounds re-prompting for the router address, so a closed
+// stdin can't loop forever
+const maxAddressPrompts = 3
+
+// routerAddress returns the validated router address from -ip or
+// ROUTEROS_IP, or else prompts for it (offering saved) until it is valid.
+func routerAddress(saved string) (string, error) {
+	if value := flagOrEnv(*ipFlag, "ROUTEROS_IP"); value != "" {
+		return normalizeHost(value)
+	}
+	var err error
+	for range maxAddressPrompts {
+		var host string
+		if host, err = normalizeHost(readInputDefault("Router IP", saved)); err == nil {
+			return host, nil
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return "", err
 }
 
-// View implements tea.Model
-func (m Model) View() string {
-	headers := []string{"IP", "MAC", "Hostname", "Vendor"}
-	sortIndicator := "↑"
-	if !m.sortAscending {
-		sortIndicator = "↓"
+// normalizeHost cleans up a router address as typed, dropping a scheme
+// such as "ssh://", a path or a trailing port, and checks that it is an IP
+// address or a hostname that resolves. Hostnames behind -jump are only
+// checked for syntax, since they may only resolve on the bastion.
+func normalizeHost(input string) (string, error) {
+	host := strings.TrimSpace(input)
+	if _, rest, found := strings.Cut(host, "://"); found {
+		host = rest
+	}
+	host, _, _ = strings.Cut(host, "/")
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		fmt.Fprintf(os.Stderr, "Ignoring port %s in the router address; use -port to change it\n", port)
+		host = h
 	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
 
-	// Add sort indicator to current column header
-	header := fmt.Sprintf("\nSorting by %s %s (← → to change column, space to toggle order)\n\n",
-		headers[m.sortColumn], sortIndicator)
+	if host == "" {
+		return "", fmt.Errorf("router address is empty")
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return host, nil
+	}
+	if !validHostname(host) {
+		return "", fmt.Errorf("%q is not a valid IP address or hostname", input)
+	}
+	if *jumpFlag != "" {
+		return host, nil
+	}
 
-	return header + m.table.View()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+	defer cancel()
+	if _, err := net.DefaultResolver.LookupHost(ctx, host); err != nil {
+		return "", fmt.Errorf("cannot resolve %q: %v", host, err)
+	}
+	return host, nil
+}
+
+// validHostname reports whether s is a syntactically valid DNS hostname
+func validHostname(s string) bool {
+	if len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(s, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// readInputDefault prompts for a value, offering saved as the default
+func readInputDefault(label, saved string) string {
+	if saved == "" {
+		return readInput(label + ": ")
+	}
+	value := readInput(fmt.Sprintf("%s [%s]: ", label, saved))
+	if value == "" {
+		return saved
+	}
+	return value
+}
+
+func connectToRouter(ctx context.Context) (*RouterConnection, error) {
+	// Try to load saved credentials
+	savedCreds, _ := loadCredentials()
+
+	// Get router IP and username. Precedence is flag > environment > saved
+	// (offered as the prompt default) > prompt. Flags and environment skip the
+	// prompt, and when the IP comes from either the optional settings below
+	// aren't prompted for.
+	interactive := flagOrEnv(*ipFlag, "ROUTEROS_IP") == ""
+	routerIP, err := routerAddress(savedCreds.IP)
+	if err != nil {
+		return nil, err
+	}
+	username := flagOrEnv(*userFlag, "ROUTEROS_USER")
+	promptedUser := username == ""
+	if promptedUser {
+		username = readInputDefault("Username", savedCreds.Username)
+	}
+
+	// Get SSH port (older credentials.json files have none, so default to 22)
+	port := savedCreds.Port
+	if port == 0 {
+		port = defaultSSHPort
+	}
+	if *portFlag != 0 {
+		port = *portFlag
+	} else if interactive {
+		if input := readInput(fmt.Sprintf("Port [%d]: ", port)); input != "" {
+			p, err := strconv.Atoi(input)
+			if err != nil {
+				return nil, fmt.Errorf("invalid port: %s", input)
+			}
+			port = p
+		}
+	}
+	if port < 1 || port > 65535 {
+		return nil, fmt.Errorf("invalid port: %d", port)
+	}
+
+	// Get key path (flag wins, otherwise offer the saved one). noKey clears
+	// a saved key, so it is forgotten and password auth is used again.
+	keyPath := *keyFlag
+	if keyPath == "" && savedCreds.KeyPath != "" {
+		keyPath = savedCreds.KeyPath
+		if interactive {
+			keyPath = readInputDefault(fmt.Sprintf("SSH key (%s for password)", noKey), savedCreds.KeyPath)
+		}
+	}
+	forgetKey := keyPath == noKey && savedCreds.KeyPath != ""
+	if keyPath == noKey {
+		keyPath = ""
+	}
+
+	// Use the key if one is configured, otherwise fall back to password (never saved)
+	var auth ssh.AuthMethod
+	if keyPath != "" {
+		signer, err := loadPrivateKey(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		auth = ssh.PublicKeys(signer)
+	} else {
+		password, err := getPassword(routerIP, username)
+		if err != nil {
+			return nil, err
+		}
+		auth = ssh.Password(password)
+	}
+
+	knownHosts, err := knownHostsFile(*knownHostsFlag)
+	if err != nil {
+		return nil, err
+	}
+	hostKeyCallback, err := knownHostsCallback(knownHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ssh.ClientConfig{
+		User:              username,
+		Auth:              []ssh.AuthMethod{auth},
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms(knownHosts, net.JoinHostPort(routerIP, strconv.Itoa(port))),
+		Timeout:           *timeoutFlag,
+	}
+
+	router := &RouterConnection{
+		config:  config,
+		address: routerIP,
+		port:    port,
+		ctx:     ctx,
+	}
+
+	if *jumpFlag != "" {
+		jumpUser, jumpAddress, err := parseJumpHost(*jumpFlag, username)
+		if err != nil {
+			return nil, err
+		}
+		jumpAuth := auth
+		if *jumpKeyFlag != "" {
+			signer, err := loadPrivateKey(*jumpKeyFlag)
+			if err != nil {
+				return nil, err
+			}
+			jumpAuth = ssh.PublicKeys(signer)
+		}
+		router.jumpAddress = jumpAddress
+		router.jumpConfig = &ssh.ClientConfig{
+			User:              jumpUser,
+			Auth:              []ssh.AuthMethod{jumpAuth},
+			HostKeyCallback:   hostKeyCallback,
+			HostKeyAlgorithms: hostKeyAlgorithms(knownHosts, jumpAddress),
+			Timeout:           *timeoutFlag,
+		}
+	}
+
+	// A mistyped password is asked for again, but only when it was typed in
+	// the first place
+	retry := keyPath == "" && passwordPrompted()
+	for attempt := 1; ; attempt++ {
+		if router.client, err = router.dial(); err == nil {
+			break
+		}
+		if !retry || !errors.Is(err, ErrAuth) || attempt == maxAuthAttempts {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Authentication failed, try again (attempt %d/%d)\n", attempt+1, maxAuthAttempts)
+		if promptedUser {
+			username = readInputDefault("Username", username)
+			config.User = username
+		}
+		auth = ssh.Password(readPassword("Password: "))
+		config.Auth = []ssh.AuthMethod{auth}
+		if router.jumpConfig != nil && *jumpKeyFlag == "" {
+			router.jumpConfig.Auth = []ssh.AuthMethod{auth}
+		}
+	}
+
+	// Only remember settings that worked
+	if rememberCredentials(interactive || promptedUser) {
+		newCreds := Credentials{
+			IP:       routerIP,
+			Username: username,
+			Port:     port,
+			KeyPath:  keyPath,
+		}
+		if err := saveCredentials(newCreds); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving credentials: %v\n", err)
+		}
+	} else if forgetKey {
+		// -key none is an explicit request, so the saved key is dropped
+		// even when nothing else from this run is remembered
+		savedCreds.KeyPath = ""
+		if err := saveCredentials(savedCreds); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving credentials: %v\n", err)
+		}
+	}
+	return router, nil
+}
+
+// rememberCredentials reports whether this run's settings belong in
+// credentials.json: only when some of them were typed at a prompt, and never
+// when ROUTEROS_* variables supplied any, so scripted runs leave the saved
+// defaults alone.
+func rememberCredentials(prompted bool) bool {
+	return prompted && os.Getenv("ROUTEROS_IP") == "" && os.Getenv("ROUTEROS_USER") == "" &&
+		os.Getenv("ROUTEROS_PASSWORD") == ""
+}
+
+// maxAuthAttempts bounds password prompts after authentication failures
+const maxAuthAttempts = 3
+
+// noKey, given to -key or at the SSH key prompt, removes the saved key
+const noKey = "none"
+
+// Classes of connection failure. Errors from connecting wrap one of these
+// when the cause is known, so callers can tell them apart with errors.Is.
+var (
+	ErrAuth        = errors.New("authentication failed")
+	ErrTimeout     = errors.New("connection timed out")
+	ErrUnreachable = errors.New("router unreachable")
+	ErrHostKey     = errors.New("host key verification failed")
+)
+
+// classifyConnError wraps a dial or SSH handshake error with its class.
+// Cancellation, errors already classified and unrecognized errors are
+// returned unchanged.
+func classifyConnError(err error) error {
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var revoked *knownhosts.RevokedError
+	switch {
+	case err == nil, errors.Is(err, context.Canceled),
+		errors.Is(err, ErrAuth), errors.Is(err, ErrTimeout), errors.Is(err, ErrUnreachable), errors.Is(err, ErrHostKey):
+		return err
+	case errors.As(err, &revoked):
+		return fmt.Errorf("%w: %w", ErrHostKey, err)
+	case strings.Contains(err.Error(), "unable to authenticate"):
+		// The ssh package has no error type for rejected credentials
+		return fmt.Errorf("%w: %w", ErrAuth, err)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	case errors.As(err, &dnsErr), errors.As(err, &opErr):
+		return fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+	return err
+}
+
+// connectHint suggests what to check for a classified connection error, or
+// returns "" when there is nothing specific to say.
+func connectHint(err error) string {
+	switch {
+	case errors.Is(err, ErrAuth):
+		return "Check the username and password or key (-user, -key)."
+	case errors.Is(err, ErrTimeout):
+		return "The router didn't answer in time; check the address and port, or raise -timeout."
+	case errors.Is(err, ErrUnreachable):
+		return "Check the address (-ip), the port (-port) and that the router's service is enabled."
+	case errors.Is(err, ErrHostKey):
+		return "The router's host key doesn't match known_hosts; if it was reinstalled, remove the old entry (-known-hosts)."
+	}
+	return ""
+}
+
+// passwordPrompted reports whether getPassword reads the password from the
+// terminal (or the keychain) rather than a flag or ROUTEROS_PASSWORD
+func passwordPrompted() bool {
+	return *passwordFlag == "" && *passwordFileFlag == "" && !*passwordStdinFlag &&
+		os.Getenv("ROUTEROS_PASSWORD") == ""
+}
+
+// dial opens a new SSH client to the router, through the bastion if one is
+// configured. Any previous bastion client is replaced.
+func (r *RouterConnection) dial() (*ssh.Client, error) {
+	address := net.JoinHostPort(r.address, strconv.Itoa(r.port))
+	if r.jumpConfig == nil {
+		return dialSSH(r.ctx, address, r.config)
+	}
+
+	if r.jumpClient != nil {
+		r.jumpClient.Close()
+		r.jumpClient = nil
+	}
+	jumpClient, err := dialSSH(r.ctx, r.jumpAddress, r.jumpConfig)
+	if err != nil {
+		return nil, fmt.Errorf("jump host %s: %w", r.jumpAddress, err)
+	}
+
+	conn, err := jumpClient.DialContext(r.ctx, "tcp", address)
+	if err != nil {
+		jumpClient.Close()
+		return nil, fmt.Errorf("%w: jump host %s could not reach %s: %w", ErrUnreachable, r.jumpAddress, address, err)
+	}
+	client, err := newSSHClient(r.ctx, conn, address, r.config)
+	if err != nil {
+		jumpClient.Close()
+		return nil, err
+	}
+
+	r.jumpClient = jumpClient
+	return client, nil
+}
+
+// dialSSH is ssh.Dial with the TCP connect and handshake cancelled by ctx
+func dialSSH(ctx context.Context, address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	d := net.Dialer{Timeout: config.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, classifyConnError(err)
+	}
+	return newSSHClient(ctx, conn, address, config)
+}
+
+// newSSHClient runs the SSH handshake over conn, closing conn if ctx is
+// cancelled before the handshake finishes or if it fails.
+func newSSHClient(ctx context.Context, conn net.Conn, address string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	c, chans, reqs, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, classifyConnError(err)
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// Close closes the router client, then the bastion client if any
+func (r *RouterConnection) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Closing the client also ends a -shell session blocked on output
+	err := r.client.Close()
+	if r.jumpClient != nil {
+		r.jumpClient.Close()
+	}
+	return err
+}
+
+// parseJumpHost splits a [user@]host[:port] bastion spec, defaulting the
+// user to defaultUser and the port to 22.
+func parseJumpHost(spec, defaultUser string) (user, address string, err error) {
+	user = defaultUser
+	if at := strings.LastIndex(spec, "@"); at >= 0 {
+		user, spec = spec[:at], spec[at+1:]
+	}
+
+	host, port, err := net.SplitHostPort(spec)
+	if err != nil {
+		// No port given
+		host, port = strings.Trim(spec, "[]"), strconv.Itoa(defaultSSHPort)
+	}
+	if host == "" || user == "" {
+		return "", "", fmt.Errorf("invalid jump host %q, expected [user@]host[:port]", spec)
+	}
+	return user, net.JoinHostPort(host, port), nil
+}
+
+// reconnect replaces a dead client, retrying with exponential backoff. stale
+// is the client that failed; if another caller already replaced it, the new
+// client is kept.
+func (r *RouterConnection) reconnect(stale *ssh.Client) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.client != stale {
+		return nil
+	}
+	r.client.Close()
+
+	backoff := initialBackoff
+	var err error
+	for attempt := 1; attempt <= maxReconnectAttempts; attempt++ {
+		tui.ReportStatus(fmt.Sprintf("Connection lost, reconnecting to %s (attempt %d/%d)...",
+			r.address, attempt, maxReconnectAttempts))
+
+		var client *ssh.Client
+		if client, err = r.dial(); err == nil {
+			r.client = client
+			tui.ReportStatus(fmt.Sprintf("Reconnected to %s", r.address))
+			return nil
+		}
+		// Trying again won't change the credentials or the host key
+		if errors.Is(err, ErrAuth) || errors.Is(err, ErrHostKey) {
+			return err
+		}
+
+		if attempt < maxReconnectAttempts {
+			if !sleepContext(r.ctx, backoff) {
+				return r.ctx.Err()
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}
+	return err
+}
+
+// sleepContext waits for d, returning false early if ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// flagOrEnv returns the flag value if set, otherwise the environment variable
+func flagOrEnv(value, env string) string {
+	if value != "" {
+		return value
+	}
+	return os.Getenv(env)
+}
+
+// getPassword returns the password for username@ip. The password flags take
+// precedence, then ROUTEROS_PASSWORD; with -keychain it is then read from the
+// OS keyring when present. Otherwise the user is prompted and, with
+// -keychain, offered to save it.
+func getPassword(ip, username string) (string, error) {
+	switch {
+	case *passwordFlag != "":
+		return *passwordFlag, nil
+	case *passwordFileFlag != "":
+		data, err := os.ReadFile(*passwordFileFlag)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password file: %v", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case *passwordStdinFlag:
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read password from stdin: %v", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	case os.Getenv("ROUTEROS_PASSWORD") != "":
+		return os.Getenv("ROUTEROS_PASSWORD"), nil
+	}
+
+	if !*keychainFlag {
+		return readPassword("Password: "), nil
+	}
+
+	account := username + "@" + ip
+	if password, err := keyring.Get(keyringService, account); err == nil {
+		return password, nil
+	} else if !errors.Is(err, keyring.ErrNotFound) {
+		fmt.Fprintf(os.Stderr, "Keyring unavailable: %v\n", err)
+		return readPassword("Password: "), nil
+	}
+
+	password := readPassword("Password: ")
+	if answer := strings.ToLower(readInput("Save password to keychain? [y/N]: ")); answer == "y" || answer == "yes" {
+		if err := keyring.Set(keyringService, account, password); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving password to keychain: %v\n", err)
+		}
+	}
+	return password, nil
+}
+
+// connectREST prompts for credentials and prepares the REST lease source.
+// The SSH-specific saved settings are left untouched.
+func connectREST(ctx context.Context) (*restLeaseSource, error) {
+	savedCreds, _ := loadCredentials()
+
+	// Same precedence as connectToRouter: flag > environment > saved > prompt
+	routerIP, err := routerAddress(savedCreds.IP)
+	if err != nil {
+		return nil, err
+	}
+	prompted := flagOrEnv(*ipFlag, "ROUTEROS_IP") == ""
+	username := flagOrEnv(*userFlag, "ROUTEROS_USER")
+	if username == "" {
+		prompted = true
+		username = readInputDefault("Username", savedCreds.Username)
+	}
+	password, err := getPassword(routerIP, username)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if *insecureFlag {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	host := routerIP
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+	source := &restLeaseSource{
+		baseURL:  "https://" + host,
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: *timeoutFlag, Transport: transport},
+		ctx:      ctx,
+	}
+
+	// Check the router answers before remembering the address and username
+	resp, err := source.get("/rest/system/identity")
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if rememberCredentials(prompted) {
+		newCreds := savedCreds
+		newCreds.IP = routerIP
+		newCreds.Username = username
+		if err := saveCredentials(newCreds); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving credentials: %v\n", err)
+		}
+	}
+	return source, nil
+}
+
+// get requests path from the REST API. Non-200 responses are returned as
+// errors; otherwise the caller closes the body.
+func (r *restLeaseSource) get(path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(r.username, r.password)
+
+	slog.Debug("REST request", "url", req.URL.String())
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("REST request failed: %w", classifyConnError(err))
+	}
+	slog.Debug("REST response", "status", resp.StatusCode, "bytes", resp.ContentLength)
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("REST %w", ErrAuth)
+		}
+		return nil, fmt.Errorf("REST request failed: %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// Leases implements LeaseSource over the REST API
+func (r *restLeaseSource) Leases() ([]routeros.DHCPLease, error) {
+	resp, err := r.get("/rest/ip/dhcp-server/lease")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// RouterOS encodes every property as a string
+	var records []map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&records); err != nil {
+		return nil, fmt.Errorf("failed to decode REST response: %v", err)
+	}
+
+	var leases []routeros.DHCPLease
+	for _, fields := range records {
+		if lease, ok := routeros.LeaseFromFields(fields); ok {
+			leases = append(leases, lease)
+		}
+	}
+	return leases, nil
+}
+
+// connectAPI prompts for credentials, opens a TLS connection to the API
+// port (-port, default 8729) and logs in. The saved SSH port is not used.
+func connectAPI(ctx context.Context) (*apiLeaseSource, error) {
+	savedCreds, _ := loadCredentials()
+
+	// Same precedence as connectToRouter: flag > environment > saved > prompt
+	routerIP, err := routerAddress(savedCreds.IP)
+	if err != nil {
+		return nil, err
+	}
+	prompted := flagOrEnv(*ipFlag, "ROUTEROS_IP") == ""
+	username := flagOrEnv(*userFlag, "ROUTEROS_USER")
+	if username == "" {
+		prompted = true
+		username = readInputDefault("Username", savedCreds.Username)
+	}
+	password, err := getPassword(routerIP, username)
+	if err != nil {
+		return nil, err
+	}
+
+	address := net.JoinHostPort(routerIP, strconv.Itoa(cmp.Or(*portFlag, defaultAPIPort)))
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: *timeoutFlag},
+		Config:    &tls.Config{InsecureSkipVerify: *insecureFlag},
+	}
+	slog.Debug("dialing RouterOS API", "address", address)
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, classifyConnError(err))
+	}
+	context.AfterFunc(ctx, func() { conn.Close() })
+
+	source := &apiLeaseSource{conn: conn, reader: bufio.NewReader(conn), address: address, ctx: ctx}
+	if err := source.login(username, password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if rememberCredentials(prompted) {
+		newCreds := savedCreds
+		newCreds.IP = routerIP
+		newCreds.Username = username
+		if err := saveCredentials(newCreds); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving credentials: %v\n", err)
+		}
+	}
+	return source, nil
+}
+
+// login authenticates the API connection. RouterOS 6.43 and later accept
+// the password directly; older versions answer with a challenge for an MD5
+// response instead.
+func (a *apiLeaseSource) login(username, password string) error {
+	reply, err := a.request("/login", "=name="+username, "=password="+password)
+	if err != nil {
+		return fmt.Errorf("API %w: %v", ErrAuth, err)
+	}
+	challenge, ok := reply.done["ret"]
+	if !ok {
+		return nil
+	}
+
+	c, err := hex.DecodeString(challenge)
+	if err != nil {
+		return fmt.Errorf("invalid API login challenge %q", challenge)
+	}
+	sum := md5.Sum(append(append([]byte{0}, password...), c...))
+	if _, err := a.request("/login", "=name="+username, "=response=00"+hex.EncodeToString(sum[:])); err != nil {
+		return fmt.Errorf("API %w: %v", ErrAuth, err)
+	}
+	return nil
+}
+
+// apiReply holds the attributes of each !re sentence of a reply, and of the
+// final !done sentence.
+type apiReply struct {
+	records []map[string]string
+	done    map[string]string
+}
+
+// request sends one API command and reads sentences up to its !done. A
+// !trap is returned as an error carrying the router's message.
+func (a *apiLeaseSource) request(words ...string) (apiReply, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	slog.Debug("API request", "command", words[0])
+	a.conn.SetDeadline(time.Now().Add(*timeoutFlag))
+	var sentence []byte
+	for _, word := range append(words, "") {
+		sentence = append(sentence, apiWordLength(len(word))...)
+		sentence = append(sentence, word...)
+	}
+	if _, err := a.conn.Write(sentence); err != nil {
+		return apiReply{}, fmt.Errorf("API request failed: %v", err)
+	}
+
+	var reply apiReply
+	var trap string
+	for {
+		kind, attrs, err := a.readSentence()
+		if err != nil {
+			return apiReply{}, fmt.Errorf("API request failed: %v", err)
+		}
+		switch kind {
+		case "!re":
+			reply.records = append(reply.records, attrs)
+		case "!trap":
+			trap = cmp.Or(attrs["message"], "command failed")
+		case "!fatal":
+			return apiReply{}, fmt.Errorf("API connection closed by router")
+		case "!done":
+			reply.done = attrs
+			slog.Debug("API response", "records", len(reply.records))
+			if trap != "" {
+				return apiReply{}, errors.New(trap)
+			}
+			return reply, nil
+		}
+	}
+}
+
+// readSentence reads one reply sentence, returning its type word (e.g. !re)
+// and its =name=value attributes.
+func (a *apiLeaseSource) readSentence() (kind string, attrs map[string]string, err error) {
+	attrs = make(map[string]string)
+	for {
+		word, err := readAPIWord(a.reader)
+		if err != nil {
+			return "", nil, err
+		}
+		switch {
+		case word == "":
+			return kind, attrs, nil
+		case kind == "":
+			kind = word
+		case strings.HasPrefix(word, "="):
+			name, value, _ := strings.Cut(word[1:], "=")
+			attrs[name] = value
+		}
+	}
+}
+
+// apiWordLength encodes a word length in the API's variable-length form:
+// the count of leading one bits in the first byte says how many bytes
+// follow.
+func apiWordLength(n int) []byte {
+	switch {
+	case n < 0x80:
+		return []byte{byte(n)}
+	case n < 0x4000:
+		return []byte{byte(n>>8) | 0x80, byte(n)}
+	case n < 0x200000:
+		return []byte{byte(n>>16) | 0xC0, byte(n >> 8), byte(n)}
+	case n < 0x10000000:
+		return []byte{byte(n>>24) | 0xE0, byte(n >> 16), byte(n >> 8), byte(n)}
+	default:
+		return []byte{0xF0, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+}
+
+// readAPIWord reads one length-prefixed word
+func readAPIWord(r *bufio.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	n, extra := int(b), 0
+	switch {
+	case b&0x80 == 0:
+	case b&0xC0 == 0x80:
+		n, extra = int(b&0x3F), 1
+	case b&0xE0 == 0xC0:
+		n, extra = int(b&0x1F), 2
+	case b&0xF0 == 0xE0:
+		n, extra = int(b&0x0F), 3
+	case b == 0xF0:
+		n, extra = 0, 4
+	default:
+		return "", fmt.Errorf("invalid API word length byte %#x", b)
+	}
+	for range extra {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		n = n<<8 | int(c)
+	}
+
+	word := make([]byte, n)
+	if _, err := io.ReadFull(r, word); err != nil {
+		return "", err
+	}
+	return string(word), nil
+}
+
+// Leases implements LeaseSource over the RouterOS API
+func (a *apiLeaseSource) Leases() ([]routeros.DHCPLease, error) {
+	reply, err := a.request("/ip/dhcp-server/lease/print")
+	if err != nil {
+		return nil, err
+	}
+
+	var leases []routeros.DHCPLease
+	for _, fields := range reply.records {
+		if lease, ok := routeros.LeaseFromFields(fields); ok {
+			leases = append(leases, lease)
+		}
+	}
+	return leases, nil
+}
+
+// Close ends the API connection
+func (a *apiLeaseSource) Close() error {
+	return a.conn.Close()
+}
+
+// loadPrivateKey reads and parses an SSH private key, prompting for the
+// passphrase when the key is encrypted.
+func loadPrivateKey(path string) (ssh.Signer, error) {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = home + path[1:]
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file %s: %v", path, err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		passphrase := readPassword("Key passphrase: ")
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key file %s: %v", path, err)
+	}
+	return signer, nil
+}
+
+// knownHostsFile resolves the known_hosts path, defaulting to
+// ~/.ssh/known_hosts, and creates the file since knownhosts.New requires it
+// to exist.
+func knownHostsFile(path string) (string, error) {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate home directory: %v", err)
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to open known hosts file: %v", err)
+	}
+	f.Close()
+	return path, nil
+}
+
+// hostKeyAlgorithms lists the host key algorithms matching the key types
+// known_hosts records for address (host:port), so the server is asked for a
+// key we can verify. Without this a host stored with its ssh-rsa key fails
+// as a mismatch once it negotiates ed25519. nil means the host is unknown
+// and the defaults apply.
+func hostKeyAlgorithms(path, address string) []string {
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil
+	}
+	portNum, _ := strconv.Atoi(port)
+	remote := &net.TCPAddr{IP: net.ParseIP(host), Port: portNum}
+	if remote.IP == nil {
+		remote.IP = net.IPv4zero
+	}
+
+	// Any key the file doesn't hold makes the callback list the known ones
+	_, probe, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil
+	}
+	signer, err := ssh.NewSignerFromKey(probe)
+	if err != nil {
+		return nil
+	}
+	var keyErr *knownhosts.KeyError
+	if !errors.As(callback(address, remote, signer.PublicKey()), &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	for _, want := range keyErr.Want {
+		keyType := want.Key.Type()
+		if keyType == ssh.KeyAlgoRSA {
+			// RSA keys are negotiated by their signature algorithm
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+			continue
+		}
+		algorithms = append(algorithms, keyType)
+	}
+	slices.Sort(algorithms)
+	return slices.Compact(algorithms)
+}
+
+// knownHostsCallback verifies host keys against a known_hosts file. Unknown
+// hosts are offered to the user and appended on acceptance; mismatches abort.
+func knownHostsCallback(path string) (ssh.HostKeyCallback, error) {
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known hosts file: %v", err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+
+		// Host is known but presented a different key
+		if len(keyErr.Want) > 0 {
+			var known []string
+			for _, want := range keyErr.Want {
+				known = append(known, fmt.Sprintf("%s (%s:%d)", ssh.FingerprintSHA256(want.Key), want.Filename, want.Line))
+			}
+			return fmt.Errorf("%w: host key mismatch for %s: got %s, expected %s", ErrHostKey,
+				hostname, ssh.FingerprintSHA256(key), strings.Join(known, ", "))
+		}
+
+		// Unknown host, ask before trusting it
+		fmt.Fprintf(os.Stderr, "The authenticity of host %s can't be established.\n", hostname)
+		fmt.Fprintf(os.Stderr, "%s key fingerprint is %s.\n", key.Type(), ssh.FingerprintSHA256(key))
+		answer := readInput("Are you sure you want to continue connecting (yes/no)? ")
+		if answer != "yes" && answer != "y" {
+			return fmt.Errorf("%w: host key for %s rejected by user", ErrHostKey, hostname)
+		}
+
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to update known hosts file: %v", err)
+		}
+		line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+		if _, err := fmt.Fprintln(f, line); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to update known hosts file: %v", err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to update known hosts file: %v", err)
+		}
+
+		// Reload so reconnects trust the key without asking again, which
+		// would read from the terminal while a viewer owns it
+		if reloaded, err := knownhosts.New(path); err == nil {
+			callback = reloaded
+		}
+		return nil
+	}, nil
+}
+
+// Run implements CommandRunner. It executes a single RouterOS command on a
+// fresh session and returns its combined output. If the connection has
+// dropped it reconnects and retries the command once.
+func (r *RouterConnection) Run(cmd string) ([]byte, error) {
+	if *shellFlag {
+		return r.runShell(cmd)
+	}
+
+	r.mu.Lock()
+	client := r.client
+	r.mu.Unlock()
+
+	slog.Debug("running command", "host", r.address, "cmd", cmd)
+	output, err := runSession(r.ctx, client, cmd)
+	var exitErr *ssh.ExitError
+	if err == nil || errors.As(err, &exitErr) || r.ctx.Err() != nil {
+		slog.Debug("command finished", "cmd", cmd, "bytes", len(output), "err", err)
+		return output, err
+	}
+	slog.Debug("command failed, reconnecting", "cmd", cmd, "err", err)
+
+	// Anything but a command exit status means the client is gone
+	if rerr := r.reconnect(client); rerr != nil {
+		return nil, fmt.Errorf("%v (reconnect failed: %v)", err, rerr)
+	}
+	r.mu.Lock()
+	client = r.client
+	r.mu.Unlock()
+	return runSession(r.ctx, client, cmd)
+}
+
+// runSession runs cmd on a new session of client, closing the session if
+// ctx is cancelled first. Exit status errors are returned unwrapped so
+// callers can tell them from connection failures.
+func runSession(ctx context.Context, client *ssh.Client, cmd string) ([]byte, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %v", err)
+	}
+	defer session.Close()
+	stop := context.AfterFunc(ctx, func() { session.Close() })
+	defer stop()
+
+	output, err := session.CombinedOutput(cmd)
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return output, err
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("%q cancelled: %v", cmd, ctx.Err())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute %q: %v", cmd, err)
+	}
+	return output, nil
+}
+
+// runShell runs cmd on the shared shell session, opening it on first use.
+// If the shell or the connection has dropped it reconnects and retries the
+// command once on a new shell.
+func (r *RouterConnection) runShell(cmd string) ([]byte, error) {
+	r.shellMu.Lock()
+	defer r.shellMu.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		r.mu.Lock()
+		client := r.client
+		r.mu.Unlock()
+
+		var err error
+		if r.shell == nil || r.shell.client != client {
+			if r.shell != nil {
+				r.shell.Close()
+			}
+			r.shell, err = openShell(r.ctx, client)
+		}
+		if err == nil {
+			slog.Debug("running command in shell", "host", r.address, "cmd", cmd)
+			var output []byte
+			if output, err = r.shell.exec(cmd); err == nil {
+				slog.Debug("command finished", "cmd", cmd, "bytes", len(output))
+				return output, nil
+			}
+			r.shell.Close()
+		}
+		r.shell = nil
+		if attempt > 1 || r.ctx.Err() != nil {
+			return nil, err
+		}
+
+		slog.Debug("shell failed, reconnecting", "cmd", cmd, "err", err)
+		if rerr := r.reconnect(client); rerr != nil {
+			return nil, fmt.Errorf("%v (reconnect failed: %v)", err, rerr)
+		}
+	}
+}
+
+// Shell terminal size. It is large enough that RouterOS neither wraps long
+// lines nor pages long print output.
+const (
+	shellWidth  = 4096
+	shellHeight = 4096
+)
+
+// routerShell is one interactive RouterOS shell that commands are written
+// to in turn. The shell has no exit status, so each command is followed by
+// a :put of a unique marker and its output is everything printed before
+// the marker.
+type routerShell struct {
+	client  *ssh.Client // the client the shell was opened on
+	session *ssh.Session
+	stdin   io.Writer
+	stdout  *bufio.Reader
+	stop    func() bool // stops closing the session when ctx is cancelled
+	seq     int
+}
+
+// openShell starts a shell session on client and reads past the login
+// banner and first prompt. The session is closed if ctx is cancelled.
+func openShell(ctx context.Context, client *ssh.Client) (*routerShell, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %v", err)
+	}
+	sh := &routerShell{client: client, session: session}
+	sh.stop = context.AfterFunc(ctx, func() { session.Close() })
+
+	fail := func(err error) (*routerShell, error) {
+		sh.Close()
+		return nil, fmt.Errorf("failed to start shell: %v", err)
+	}
+	// A dumb terminal keeps RouterOS from redrawing the line as we type
+	if err := session.RequestPty("dumb", shellHeight, shellWidth, ssh.TerminalModes{ssh.ECHO: 0}); err != nil {
+		return fail(err)
+	}
+	if sh.stdin, err = session.StdinPipe(); err != nil {
+		return fail(err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return fail(err)
+	}
+	sh.stdout = bufio.NewReader(stdout)
+	if err := session.Shell(); err != nil {
+		return fail(err)
+	}
+	if _, err := sh.exec(""); err != nil {
+		return fail(err)
+	}
+	return sh, nil
+}
+
+// ansiEscape matches the terminal control sequences RouterOS may print
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// exec writes cmd, or nothing if cmd is empty, and returns its output with
+// the echoed input and prompts left out.
+func (sh *routerShell) exec(cmd string) ([]byte, error) {
+	sh.seq++
+	marker := fmt.Sprintf("--routeros-tools-%d--", sh.seq)
+	// Split in the script so the echoed :put line never matches the marker
+	half := len(marker) / 2
+	put := fmt.Sprintf(":put (%q . %q)", marker[:half], marker[half:])
+
+	input := put + "\r\n"
+	if cmd != "" {
+		input = cmd + "\r\n" + input
+	}
+	if _, err := io.WriteString(sh.stdin, input); err != nil {
+		return nil, err
+	}
+
+	var output []string
+	for first := true; ; first = false {
+		line, err := sh.stdout.ReadString('\n')
+		clean := strings.TrimRight(ansiEscape.ReplaceAllString(line, ""), "\r\n")
+		if clean == marker {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// The command is echoed once, as the first line after its prompt
+		if rest, ok := stripEcho(clean, cmd); first && cmd != "" && ok && rest == "" {
+			continue
+		}
+		// The :put follows the output, on the same line if the output had
+		// no trailing newline
+		if rest, ok := stripEcho(clean, put); ok {
+			if rest == "" {
+				continue
+			}
+			clean = rest
+		}
+		output = append(output, clean)
+	}
+	if len(output) == 0 {
+		return nil, nil
+	}
+	return []byte(strings.Join(output, "\n") + "\n"), nil
+}
+
+// shellPrompt matches a RouterOS prompt such as "[admin@MikroTik] > " or
+// "[admin@MikroTik] /ip dhcp-server> " at the end of a line
+var shellPrompt = regexp.MustCompile(`\[[^][]+\] [^>]*> ?$`)
+
+// stripEcho reports whether line ends with a prompt followed by input, and
+// returns whatever was printed before the prompt.
+func stripEcho(line, input string) (string, bool) {
+	prefix, ok := strings.CutSuffix(line, input)
+	if !ok {
+		return line, false
+	}
+	loc := shellPrompt.FindStringIndex(prefix)
+	if loc == nil {
+		return line, false
+	}
+	return prefix[:loc[0]], true
+}
+
+// Close ends the shell session
+func (sh *routerShell) Close() error {
+	sh.stop()
+	return sh.session.Close()
+}
+
+// Leases implements LeaseSource over SSH
+func (r *RouterConnection) Leases() ([]routeros.DHCPLease, error) {
+	return listLeases(r)
+}
+
+// listLeases reads the DHCP leases through the RouterOS CLI
+func listLeases(runner CommandRunner) ([]routeros.DHCPLease, error) {
+	// Execute command to get leases with terse output
+	output, err := runner.Run(leaseCommand)
+	if err != nil {
+		return nil, err
+	}
+
+	// Process output
+	leases, err := routeros.ParseLeases(string(output))
+	if err != nil {
+		tui.ReportStatus("Lease output was neither terse nor columnar, no leases parsed (see -debug)")
+		slog.Debug("unparsed lease output", "output", string(output))
+	}
+	addLeaseIDs(runner, leases)
+	return leases, nil
+}
+
+// addLeaseIDs fills in the RouterOS .id of each lease, matched on address
+// and MAC. Failures are logged and leave the IDs empty.
+func addLeaseIDs(runner CommandRunner, leases []routeros.DHCPLease) {
+	output, err := runner.Run(leaseIDCommand)
+	if err != nil {
+		slog.Debug("lease ID lookup failed", "error", err)
+		return
+	}
+	ids := make(map[string]string)
+	for _, record := range routeros.ParseTerse(string(output)) {
+		f := record.Fields
+		ids[f["address"]+" "+macvendor.MACKey(f["mac-address"])] = f["id"]
+	}
+	for i := range leases {
+		if leases[i].ID == "" {
+			leases[i].ID = ids[leases[i].Address+" "+macvendor.MACKey(leases[i].MacAddress)]
+		}
+	}
+}
+
+// subnets limits fetched leases to these networks, from -subnet
+var subnets []netip.Prefix
+
+// addBridgePorts fills in the bridge port each lease's MAC was learned on,
+// from one listing of the bridge host table. Routers without a bridge, or
+// a failed listing, leave the ports empty.
+func addBridgePorts(runner CommandRunner, leases []routeros.DHCPLease) {
+	output, err := runner.Run(bridgeHostCommand)
+	if err != nil {
+		slog.Debug("bridge host lookup failed", "error", err)
+		return
+	}
+	ports := make(map[string]string)
+	for _, record := range routeros.ParseTerse(string(output)) {
+		// Local entries are the router's own interfaces
+		if strings.ContainsRune(record.Flags, 'L') {
+			continue
+		}
+		mac := macvendor.MACKey(record.Fields["mac-address"])
+		if _, exists := ports[mac]; !exists {
+			ports[mac] = cmp.Or(record.Fields["on-interface"], record.Fields["interface"])
+		}
+	}
+	for i := range leases {
+		leases[i].Port = ports[macvendor.MACKey(leases[i].MacAddress)]
+	}
+}
+
+// fetchLeases retrieves the DHCP leases from the source, keeps those in
+// -subnet, and enriches them with vendor information and, over SSH, the
+// bridge port each one is connected to.
+func fetchLeases(source LeaseSource) ([]routeros.DHCPLease, error) {
+	leases, err := source.Leases()
+	if err != nil {
+		return nil, err
+	}
+	if subnets != nil {
+		leases = slices.DeleteFunc(leases, func(lease routeros.DHCPLease) bool {
+			return !routeros.InSubnets(lease.Address, subnets)
+		})
+	}
+	if runner, ok := source.(CommandRunner); ok {
+		addBridgePorts(runner, leases)
+	}
+
+	// Saved leases were enriched when exported, and looking them up again
+	// would mix in this network's DNS and last-seen times
+	if _, ok := source.(fileLeaseSource); ok {
+		anonymizeLeases(leases)
+		return leases, nil
+	}
+
+	// Hostname lookups run alongside vendor enrichment; they write
+	// different fields of each lease
+	ctx := sourceContext(source)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		resolveHostnames(ctx, source, leases)
+	}()
+	enrichLeases(ctx, leases)
+	wg.Wait()
+
+	// Only bound leases show the device is actually present
+	var present []string
+	for _, lease := range leases {
+		if lease.Status == "bound" {
+			present = append(present, lease.MacAddress)
+		}
+	}
+	seen := recordSeen(present)
+	for i := range leases {
+		if t, ok := seen[macvendor.MACKey(leases[i].MacAddress)]; ok {
+			leases[i].LastSeen = &t
+		}
+	}
+
+	// Masked last, so vendors, hostnames and last-seen times were looked up
+	// from the real values
+	anonymizeLeases(leases)
+	return leases, nil
+}
+
+// anonymizeKey keys the -anonymize pseudonyms. It is random for each run,
+// so the same device gets the same pseudonym throughout one capture but
+// can't be matched across captures.
+var anonymizeKey = sync.OnceValue(func() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+})
+
+// pseudonym derives stable pseudonym bytes for a value of the given kind
+func pseudonym(kind, value string) []byte {
+	h := hmac.New(sha256.New, anonymizeKey())
+	h.Write([]byte(kind + ":" + value))
+	return h.Sum(nil)
+}
+
+// anonymizeMAC replaces mac with a pseudonymous locally administered
+// unicast MAC, so it can't be mistaken for a real vendor's address.
+func anonymizeMAC(mac string) string {
+	if mac == "" {
+		return ""
+	}
+	b := pseudonym("mac", macvendor.MACKey(mac))[:6]
+	b[0] = b[0]&^0x01 | 0x02
+	return strings.ToUpper(net.HardwareAddr(b).String())
+}
+
+// anonymizeHostname replaces name with a pseudonym such as "host-1a2b3c4d"
+func anonymizeHostname(name string) string {
+	if name == "" {
+		return ""
+	}
+	return "host-" + hex.EncodeToString(pseudonym("host", strings.ToLower(name))[:4])
+}
+
+// maskIP zeroes the host part of addr past /24 for IPv4 and /64 for IPv6.
+// Anything that isn't an address is returned unchanged.
+func maskIP(addr string) string {
+	a, err := netip.ParseAddr(addr)
+	if err != nil {
+		return addr
+	}
+	bits := 24
+	if a.Is6() {
+		bits = 64
+	}
+	prefix, err := a.Prefix(bits)
+	if err != nil {
+		return addr
+	}
+	return prefix.Addr().String()
+}
+
+// anonymizeLeases applies -anonymize and -mask-ips to leases in place
+func anonymizeLeases(leases []routeros.DHCPLease) {
+	for i := range leases {
+		lease := &leases[i]
+		if *anonymizeFlag {
+			lease.MacAddress = anonymizeMAC(lease.MacAddress)
+			lease.Hostname = anonymizeHostname(lease.Hostname)
+		}
+		if *maskIPsFlag {
+			lease.Address = maskIP(lease.Address)
+		}
+	}
+}
+
+// runnerContext returns the context that cancels commands sent to runner
+func runnerContext(runner CommandRunner) context.Context {
+	if source, ok := runner.(LeaseSource); ok {
+		return sourceContext(source)
+	}
+	return context.Background()
+}
+
+// sourceContext returns the context that cancels requests to source
+func sourceContext(source LeaseSource) context.Context {
+	switch source := source.(type) {
+	case *RouterConnection:
+		return source.ctx
+	case *restLeaseSource:
+		return source.ctx
+	case *apiLeaseSource:
+		return source.ctx
+	}
+	return context.Background()
+}
+
+// resolveHostnames fills in missing lease hostnames from reverse DNS and,
+// for SSH sources, the router's static and cached DNS entries.
+func resolveHostnames(ctx context.Context, source LeaseSource, leases []routeros.DHCPLease) {
+	var missing []int
+	for i := range leases {
+		if leases[i].Hostname == "" && leases[i].Address != "" {
+			missing = append(missing, i)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for w := 0; w < dnsLookupWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if name := reverseLookup(ctx, leases[i].Address); name != "" {
+					leases[i].Hostname = name
+					leases[i].HostnameFromDNS = true
+				}
+			}
+		}()
+	}
+	for _, i := range missing {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	runner, ok := source.(CommandRunner)
+	if !ok {
+		return
+	}
+	var names map[string]string
+	for _, i := range missing {
+		if leases[i].Hostname != "" {
+			continue
+		}
+		if names == nil {
+			var err error
+			if names, err = dnsEntries(runner); err != nil {
+				slog.Debug("router DNS lookup failed", "error", err)
+				return
+			}
+		}
+		if name := names[leases[i].Address]; name != "" {
+			leases[i].Hostname = name
+			leases[i].HostnameFromDNS = true
+		}
+	}
+}
+
+// reverseLookup returns the first PTR name for ip, or "" if there is none.
+// Results are cached for the rest of the run, unless ctx was cancelled.
+func reverseLookup(ctx context.Context, ip string) string {
+	dnsNamesMu.Lock()
+	name, cached := dnsNames[ip]
+	dnsNamesMu.Unlock()
+	if cached {
+		return name
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, dnsLookupTimeout)
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(lookupCtx, ip)
+	if ctx.Err() != nil {
+		return ""
+	}
+	if err != nil {
+		slog.Debug("reverse DNS lookup failed", "ip", ip, "error", err)
+	} else if len(names) > 0 {
+		name = strings.TrimSuffix(names[0], ".")
+	}
+
+	dnsNamesMu.Lock()
+	dnsNames[ip] = name
+	dnsNamesMu.Unlock()
+	return name
+}
+
+// dnsEntries maps addresses to names from the router's static DNS entries
+// and DNS cache. Static entries win over cached ones.
+func dnsEntries(runner CommandRunner) (map[string]string, error) {
+	names := make(map[string]string)
+	for _, cmd := range []string{dnsCacheCommand, dnsStaticCommand} {
+		output, err := runner.Run(cmd)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range routeros.ParseTerse(string(output)) {
+			// RouterOS v6 reports address=, v7 puts A records in data=
+			addr := record.Fields["address"]
+			if addr == "" && record.Fields["type"] == "A" {
+				addr = record.Fields["data"]
+			}
+			if name := record.Fields["name"]; addr != "" && name != "" {
+				names[addr] = name
+			}
+		}
+	}
+	return names, nil
+}
+
+// enrichLeases fills in the vendor for each lease, recording an error on
+// leases whose MAC address can't be parsed.
+func enrichLeases(ctx context.Context, leases []routeros.DHCPLease) {
+	var macs []string
+	for i := range leases {
+		if _, err := macvendor.NormalizeMAC(leases[i].MacAddress); err != nil {
+			leases[i].Error = err.Error()
+			continue
+		}
+		macs = append(macs, leases[i].MacAddress)
+	}
+
+	vendors := resolveVendors(ctx, macs)
+	for i := range leases {
+		if leases[i].Error == "" {
+			leases[i].Vendor = macvendor.For(leases[i].MacAddress, vendors)
+		}
+	}
+}
+
+// resolveVendors looks up the vendor of every valid MAC address, returning a
+// map keyed by OUI. Invalid MACs are skipped, and nothing is looked up with
+// -no-vendor.
+func resolveVendors(ctx context.Context, macs []string) map[string]string {
+	if *noVendorFlag {
+		return map[string]string{}
+	}
+
+	return macvendor.Resolve(ctx, macs)
+}
+
+func viewDHCPLeases(source LeaseSource) {
+	fetch := func() ([]table.Row, error) {
+		leases, err := fetchLeases(source)
+		_, rows := orderLeaseColumns(leaseRows(leases))
+		return rows, err
+	}
+	rows, err := tui.LoadRows("Fetching leases...", fetch)
+	if err != nil {
+		fmt.Printf("Error fetching leases: %v\n", err)
+		return
+	}
+
+	// Management actions need the RouterOS CLI, and the real addresses
+	var actions []tui.Action
+	if runner, ok := source.(CommandRunner); ok && !*anonymizeFlag && !*maskIPsFlag {
+		actions = leaseActions(runner)
+	}
+
+	// A -subnet filter drops leases before they reach the table
+	var note string
+	if subnets != nil {
+		note = fmt.Sprintf("Showing leases in %s only (-subnet)", *subnetFlag)
+	}
+
+	// Display table
+	columns, _ := orderLeaseColumns(nil)
+	tui.PrintTable(tui.Table{
+		Name:    "leases",
+		Noun:    "leases",
+		Columns: columns,
+		Rows:    rows,
+		Fetch:   fetch,
+		Actions: actions,
+		Hidden:  hiddenLeaseColumns(),
+		Note:    note,
+	})
+}
+
+// vendorGroup is the leases sharing a vendor in the vendor view
+type vendorGroup struct {
+	vendor   string
+	leases   []routeros.DHCPLease
+	expanded bool
+}
+
+// groupByVendor groups leases by vendor, largest group first and then by
+// name. Members are sorted by IP.
+func groupByVendor(leases []routeros.DHCPLease) []vendorGroup {
+	byVendor := make(map[string][]routeros.DHCPLease)
+	for _, lease := range leases {
+		vendor := cmp.Or(lease.Vendor, "Unknown")
+		byVendor[vendor] = append(byVendor[vendor], lease)
+	}
+
+	groups := make([]vendorGroup, 0, len(byVendor))
+	for vendor, members := range byVendor {
+		slices.SortFunc(members, func(a, b routeros.DHCPLease) int {
+			return tui.CompareCells("IP", a.Address, b.Address)
+		})
+		groups = append(groups, vendorGroup{vendor: vendor, leases: members})
+	}
+	slices.SortFunc(groups, func(a, b vendorGroup) int {
+		return cmp.Or(len(b.leases)-len(a.leases), strings.Compare(a.vendor, b.vendor))
+	})
+	return groups
+}
+
+func viewVendors(source LeaseSource) {
+	var leases []routeros.DHCPLease
+	_, err := tui.LoadRows("Fetching leases...", func() ([]table.Row, error) {
+		var err error
+		leases, err = fetchLeases(source)
+		return nil, err
+	})
+	if err != nil {
+		fmt.Printf("Error fetching leases: %v\n", err)
+		return
+	}
+	groups := groupByVendor(leases)
+
+	if !tui.Interactive() {
+		rows := make([]table.Row, len(groups))
+		for i, g := range groups {
+			rows[i] = table.Row{g.vendor, strconv.Itoa(len(g.leases))}
+		}
+		if err := tui.PrintPlain(os.Stdout, vendorColumns, rows, nil); err != nil {
+			fmt.Printf("Error printing table: %v\n", err)
+		}
+		return
+	}
+
+	p := tea.NewProgram(vendorModel{groups: groups, total: len(leases)})
+	if _, err := tui.RunProgram(p); err != nil {
+		fmt.Printf("Error running program: %v", err)
+	}
+}
+
+// vendorColumns are used for the vendor summary in plain output
+var vendorColumns = []table.Column{
+	{Title: "Vendor", Width: 30},
+	{Title: "Devices", Width: 8},
+}
+
+// vendorModel lists vendors with their device counts. Enter expands a
+// vendor to show its leases.
+type vendorModel struct {
+	groups []vendorGroup
+	total  int // leases across all groups
+	cursor int // selected group
+	offset int // first line on screen
+	height int // terminal height, 0 until known
+}
+
+// Init implements tea.Model
+func (m vendorModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements tea.Model
+func (m vendorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			return m, tea.Quit
+		case "up", "k":
+			m.cursor = max(m.cursor-1, 0)
+		case "down", "j":
+			m.cursor = min(m.cursor+1, len(m.groups)-1)
+		case "home", "g":
+			m.cursor = 0
+		case "end", "G":
+			m.cursor = len(m.groups) - 1
+		case "enter", " ":
+			if len(m.groups) > 0 {
+				m.groups[m.cursor].expanded = !m.groups[m.cursor].expanded
+			}
+		case "a":
+			// Expand everything, or collapse everything if all are open
+			expand := slices.ContainsFunc(m.groups, func(g vendorGroup) bool { return !g.expanded })
+			for i := range m.groups {
+				m.groups[i].expanded = expand
+			}
+		}
+		m.scroll()
+	}
+	return m, nil
+}
+
+// vendorHeaderHeight and vendorFooterHeight are the lines around the list
+const (
+	vendorHeaderHeight = 4
+	vendorFooterHeight = 2
+)
+
+// scroll keeps the selected vendor's line on screen
+func (m *vendorModel) scroll() {
+	m.cursor = max(min(m.cursor, len(m.groups)-1), 0)
+	if m.height == 0 {
+		return
+	}
+	visible := max(m.height-vendorHeaderHeight-vendorFooterHeight, 1)
+	line := 0
+	for _, g := range m.groups[:m.cursor] {
+		line++
+		if g.expanded {
+			line += len(g.leases)
+		}
+	}
+	if line < m.offset {
+		m.offset = line
+	} else if line >= m.offset+visible {
+		m.offset = line - visible + 1
+	}
+}
+
+// View implements tea.Model
+func (m vendorModel) View() string {
+	selected := lipgloss.NewStyle().Foreground(lipgloss.Color("229")).Background(lipgloss.Color("57"))
+	member := lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
+
+	var lines []string
+	for i, g := range m.groups {
+		mark := "[+]"
+		if g.expanded {
+			mark = "[-]"
+		}
+		line := fmt.Sprintf("  %s %-40s %4d", mark, g.vendor, len(g.leases))
+		if i == m.cursor {
+			line = selected.Render(">" + line[1:])
+		}
+		lines = append(lines, line)
+		if g.expanded {
+			for _, lease := range g.leases {
+				lines = append(lines, member.Render(fmt.Sprintf("        %-15s  %-17s  %s",
+					lease.Address, lease.MacAddress, leaseHostname(lease))))
+			}
+		}
+	}
+	if m.height > 0 {
+		visible := max(m.height-vendorHeaderHeight-vendorFooterHeight, 1)
+		lines = lines[min(m.offset, len(lines)):min(m.offset+visible, len(lines))]
+	}
+
+	header := fmt.Sprintf("\n%sVendors: %d devices from %d vendors\n\n", tui.RouterHeader(), m.total, len(m.groups))
+	footer := "\n↑/↓ move · enter expand/collapse · a expand/collapse all · q quit"
+	return header + strings.Join(lines, "\n") + "\n" + footer
+}
+
+// fetchARP retrieves the ARP table, enriched with vendor information and
+// whether each MAC also holds a DHCP lease.
+func fetchARP(runner CommandRunner) ([]routeros.ARPEntry, error) {
+	output, err := runner.Run(arpCommand)
+	if err != nil {
+		return nil, err
+	}
+	entries := routeros.ParseARP(string(output))
+
+	leases, err := listLeases(runner)
+	if err != nil {
+		return nil, err
+	}
+	leased := make(map[string]bool)
+	for _, lease := range leases {
+		leased[macvendor.MACKey(lease.MacAddress)] = true
+	}
+
+	var macs, present []string
+	for _, entry := range entries {
+		macs = append(macs, entry.MacAddress)
+		if arpPresent(entry) {
+			present = append(present, entry.MacAddress)
+		}
+	}
+	recordSeen(present)
+	vendors := resolveVendors(runnerContext(runner), macs)
+
+	for i := range entries {
+		entries[i].HasLease = leased[macvendor.MACKey(entries[i].MacAddress)]
+		if _, err := macvendor.NormalizeMAC(entries[i].MacAddress); err == nil {
+			entries[i].Vendor = macvendor.For(entries[i].MacAddress, vendors)
+		}
+		if *anonymizeFlag {
+			entries[i].MacAddress = anonymizeMAC(entries[i].MacAddress)
+		}
+		if *maskIPsFlag {
+			entries[i].Address = maskIP(entries[i].Address)
+		}
+	}
+	return entries, nil
+}
+
+// arpPresent reports whether an ARP entry shows its device on the network.
+// Incomplete, failed and invalid entries, and those with a missing or
+// all-zero MAC, are the router still trying to resolve an address.
+func arpPresent(entry routeros.ARPEntry) bool {
+	mac, err := macvendor.NormalizeMAC(entry.MacAddress)
+	if err != nil || mac == "00:00:00:00:00:00" {
+		return false
+	}
+	switch entry.Status {
+	case "incomplete", "failed", "invalid":
+		return false
+	}
+	return true
+}
+
+var arpColumns = []table.Column{
+	{Title: "IP", Width: 15},
+	{Title: "MAC", Width: 17},
+	{Title: "Interface", Width: 15},
+	{Title: "Vendor", Width: 30},
+	{Title: "DHCP", Width: 6},
+}
+
+// arpRows converts ARP entries to table rows, flagging MACs without a lease.
+func arpRows(entries []routeros.ARPEntry) []table.Row {
+	var rows []table.Row
+	for _, entry := range entries {
+		lease := "yes"
+		if !entry.HasLease {
+			lease = "no"
+		}
+		rows = append(rows, table.Row{
+			entry.Address,
+			entry.MacAddress,
+			entry.Interface,
+			entry.Vendor,
+			lease,
+		})
+	}
+	return rows
+}
+
+func viewARP(runner CommandRunner) {
+	fetch := func() ([]table.Row, error) {
+		entries, err := fetchARP(runner)
+		return arpRows(entries), err
+	}
+	rows, err := tui.LoadRows("Fetching ARP table...", fetch)
+	if err != nil {
+		fmt.Printf("Error fetching ARP table: %v\n", err)
+		return
+	}
+
+	tui.PrintTable(tui.Table{Name: "arp", Columns: arpColumns, Rows: rows, Fetch: fetch})
+}
+
+// fetchIPv6 lists DHCPv6 bindings and IPv6 neighbors, enriched with vendor
+// information where a MAC is known. Routers without DHCPv6 reject the
+// binding command, so it only fails if both commands do.
+func fetchIPv6(runner CommandRunner) ([]routeros.IPv6Host, error) {
+	var hosts []routeros.IPv6Host
+	var failed []error
+	for _, src := range []struct{ name, cmd string }{
+		{"dhcp", ipv6BindingCommand},
+		{"neighbor", ipv6NeighborCommand},
+	} {
+		output, err := runner.Run(src.cmd)
+		if err != nil {
+			slog.Debug("IPv6 command failed", "cmd", src.cmd, "error", err)
+			failed = append(failed, err)
+			continue
+		}
+		hosts = append(hosts, routeros.ParseIPv6Hosts(string(output), src.name)...)
+	}
+	if len(failed) == 2 {
+		return nil, failed[0]
+	}
+
+	var macs []string
+	for _, host := range hosts {
+		macs = append(macs, host.MacAddress)
+	}
+	vendors := resolveVendors(runnerContext(runner), macs)
+	for i := range hosts {
+		if _, err := macvendor.NormalizeMAC(hosts[i].MacAddress); err == nil {
+			hosts[i].Vendor = macvendor.For(hosts[i].MacAddress, vendors)
+		}
+	}
+	return hosts, nil
+}
+
+var ipv6Columns = []table.Column{
+	{Title: "IP", Width: 39},
+	{Title: "MAC", Width: 17},
+	{Title: "Interface", Width: 12},
+	{Title: "Vendor", Width: 25},
+	{Title: "Status", Width: 10},
+	{Title: "Source", Width: 8},
+	{Title: "DUID", Width: 20},
+}
+
+func ipv6Rows(hosts []routeros.IPv6Host) []table.Row {
+	var rows []table.Row
+	for _, host := range hosts {
+		rows = append(rows, table.Row{
+			host.Address,
+			host.MacAddress,
+			host.Interface,
+			host.Vendor,
+			host.Status,
+			host.Source,
+			host.DUID,
+		})
+	}
+	return rows
+}
+
+func viewIPv6(runner CommandRunner) {
+	fetch := func() ([]table.Row, error) {
+		hosts, err := fetchIPv6(runner)
+		return ipv6Rows(hosts), err
+	}
+	rows, err := tui.LoadRows("Fetching IPv6 hosts...", fetch)
+	if err != nil {
+		fmt.Printf("Error fetching IPv6 hosts: %v\n", err)
+		return
+	}
+
+	tui.PrintTable(tui.Table{Name: "ipv6", Columns: ipv6Columns, Rows: rows, Fetch: fetch})
+}
+
+// fetchNeighbors retrieves the discovered neighbors, enriched with vendor
+// information.
+func fetchNeighbors(runner CommandRunner) ([]routeros.Neighbor, error) {
+	output, err := runner.Run(neighborCommand)
+	if err != nil {
+		return nil, err
+	}
+	neighbors := routeros.ParseNeighbors(string(output))
+
+	var macs []string
+	for _, n := range neighbors {
+		macs = append(macs, n.MacAddress)
+	}
+	vendors := resolveVendors(runnerContext(runner), macs)
+	for i := range neighbors {
+		if _, err := macvendor.NormalizeMAC(neighbors[i].MacAddress); err == nil {
+			neighbors[i].Vendor = macvendor.For(neighbors[i].MacAddress, vendors)
+		}
+	}
+	return neighbors, nil
+}
+
+var neighborColumns = []table.Column{
+	{Title: "Identity", Width: 20},
+	{Title: "IP", Width: 15},
+	{Title: "MAC", Width: 17},
+	{Title: "Interface", Width: 12},
+	{Title: "Platform", Width: 10},
+	{Title: "Version", Width: 16},
+	{Title: "Vendor", Width: 25},
+}
+
+func neighborRows(neighbors []routeros.Neighbor) []table.Row {
+	var rows []table.Row
+	for _, n := range neighbors {
+		rows = append(rows, table.Row{
+			n.Identity,
+			n.Address,
+			n.MacAddress,
+			n.Interface,
+			n.Platform,
+			n.Version,
+			n.Vendor,
+		})
+	}
+	return rows
+}
+
+// destructiveWords are command words that change or wipe router state in
+// ways that are hard to undo; the console asks before sending them.
+var destructiveWords = []string{
+	"remove", "reset", "reset-configuration", "reboot", "shutdown",
+	"disable", "unset", "format-drive", "downgrade", "uninstall",
+}
+
+// isDestructive reports whether a console command contains one of
+// destructiveWords as a whole word, e.g. "/ip address remove 0"
+func isDestructive(cmd string) bool {
+	for _, word := range strings.FieldsFunc(strings.ToLower(cmd), func(r rune) bool {
+		return r == ' ' || r == '/' || r == '\t' || r == ';' || r == '['
+	}) {
+		if slices.Contains(destructiveWords, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// runConsole reads RouterOS commands and prints their raw output until the
+// user types exit or closes stdin. Each command runs on a fresh session of
+// the existing connection, which reconnects if it drops.
+func runConsole(runner CommandRunner) {
+	fmt.Println("RouterOS console. Type exit or quit (or press ctrl+d) to return.")
+	prompt := "> "
+	if source, ok := runner.(LeaseSource); ok {
+		prompt = fmt.Sprintf("[%s] > ", sourceAddress(source, nil))
+	}
+	for {
+		fmt.Fprint(os.Stderr, prompt)
+		line, err := stdin.ReadString('\n')
+		cmd := strings.TrimSpace(line)
+		if err != nil && cmd == "" {
+			fmt.Fprintln(os.Stderr)
+			return
+		}
+		switch cmd {
+		case "":
+			continue
+		case "exit", "quit":
+			return
+		}
+
+		if isDestructive(cmd) {
+			answer := strings.ToLower(readInput("This command may change or remove configuration. Run it? [y/N]: "))
+			if answer != "y" && answer != "yes" {
+				fmt.Println("Skipped.")
+				continue
+			}
+		}
+		output, err := runner.Run(cmd)
+		os.Stdout.Write(output)
+		if len(output) > 0 && output[len(output)-1] != '\n' {
+			fmt.Println()
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	}
+}
+
+func viewNeighbors(runner CommandRunner) {
+	fetch := func() ([]table.Row, error) {
+		neighbors, err := fetchNeighbors(runner)
+		return neighborRows(neighbors), err
+	}
+	rows, err := tui.LoadRows("Fetching neighbors...", fetch)
+	if err != nil {
+		fmt.Printf("Error fetching neighbors: %v\n", err)
+		return
+	}
+
+	tui.PrintTable(tui.Table{Name: "neighbors", Columns: neighborColumns, Rows: rows, Fetch: fetch})
+}
+
+func fetchPoolUsage(runner CommandRunner) ([]routeros.PoolUsage, error) {
+	output, err := runner.Run(poolCommand)
+	if err != nil {
+		return nil, err
+	}
+	leases, err := listLeases(runner)
+	if err != nil {
+		return nil, err
+	}
+	return routeros.ParsePoolUsage(string(output), leases)
+}
+
+// viewPoolUsage prints a utilization panel for every IP pool, highlighting
+// pools at or above -pool-warning.
+func viewPoolUsage(runner CommandRunner) {
+	pools, err := fetchPoolUsage(runner)
+	if err != nil {
+		fmt.Printf("Error fetching pool utilization: %v\n", err)
+		return
+	}
+	if len(pools) == 0 {
+		fmt.Println("No IP pools configured.")
+		return
+	}
+
+	label := lipgloss.NewStyle().Width(16).Foreground(lipgloss.Color("240"))
+
+	var lines []string
+	for _, pool := range pools {
+		usage := fmt.Sprintf("%d/%d used, %d free (%.0f%%)  %s",
+			pool.Used, pool.Size, pool.Size-pool.Used, pool.Percent(), pool.Ranges)
+		if pool.Percent() >= float64(*poolWarningFlag) {
+			usage = tui.WarningText(usage)
+		}
+		lines = append(lines, label.Render(pool.Name)+usage)
+	}
+
+	fmt.Println(lipgloss.NewStyle().
+		Border(tui.Border(lipgloss.RoundedBorder())).
+		BorderForeground(lipgloss.Color("240")).
+		Padding(0, 1).
+		Render(strings.Join(lines, "\n")))
+}
+
+// printLeasesJSON writes the enriched leases to stdout as indented JSON.
+func printLeasesJSON(source LeaseSource) error {
+	leases, err := fetchLeases(source)
+	if err != nil {
+		return err
+	}
+	if leases == nil {
+		leases = []routeros.DHCPLease{}
+	}
+	if selectedFields != nil {
+		return printFieldsJSON(os.Stdout, leases)
+	}
+
+	data, err := json.MarshalIndent(leases, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// printFieldsJSON writes the -fields columns of each lease as a JSON object
+// keyed by field name, in the order given, with values as the viewer shows
+// them.
+func printFieldsJSON(w io.Writer, leases []routeros.DHCPLease) error {
+	columns, rows := selectLeaseColumns(leaseRows(leases))
+	var b strings.Builder
+	b.WriteString("[")
+	for r, row := range rows {
+		if r > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n    {")
+		for c, col := range columns {
+			key, _ := json.Marshal(fieldName(col.Title))
+			value, _ := json.Marshal(row[c])
+			if c > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, "\n        %s: %s", key, value)
+		}
+		b.WriteString("\n    }")
+	}
+	if len(rows) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("]\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// printLeasesPlain writes the enriched leases to w as an aligned text
+// table, sorted by IP like the viewer.
+func printLeasesPlain(w io.Writer, source LeaseSource) error {
+	leases, err := fetchLeases(source)
+	if err != nil {
+		return err
+	}
+	rows := leaseRows(leases)
+	slices.SortFunc(rows, func(a, b table.Row) int {
+		return tui.CompareCells("IP", a[0], b[0])
+	})
+	columns, rows := selectLeaseColumns(rows)
+	return tui.PrintPlain(w, columns, rows, hiddenLeaseColumns())
+}
+
+// fileLeaseSource serves leases saved earlier, for browsing them without
+// access to the router. The file is re-read on every refresh.
+type fileLeaseSource struct {
+	path string
+}
+
+// Leases implements LeaseSource from a saved file
+func (f fileLeaseSource) Leases() ([]routeros.DHCPLease, error) {
+	return loadLeaseFile(f.path)
+}
+
+// loadLeaseFile reads leases from a -json export, a -snapshot file or, for
+// .csv files, a CSV exported from the lease viewer
+func loadLeaseFile(path string) ([]routeros.DHCPLease, error) {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return loadLeaseCSV(path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var leases []routeros.DHCPLease
+	if err := json.Unmarshal(data, &leases); err == nil {
+		return leases, nil
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("%s is neither a lease export nor a snapshot: %v", path, err)
+	}
+	return snap.Leases, nil
+}
+
+// loadLeaseCSV reads a CSV exported from the lease viewer, matching columns
+// by their titles. Columns hidden at export time are left empty.
+func loadLeaseCSV(path string) ([]routeros.DHCPLease, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	header := make(map[string]int)
+	for i, title := range records[0] {
+		header[title] = i
+	}
+	if _, ok := header["MAC"]; !ok {
+		return nil, fmt.Errorf("%s has no MAC column, is it a lease export?", path)
+	}
+
+	var leases []routeros.DHCPLease
+	for _, record := range records[1:] {
+		cell := func(title string) string {
+			if i, ok := header[title]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		lease := routeros.DHCPLease{
+			ID:         cell("ID"),
+			Address:    cell("IP"),
+			MacAddress: cell("MAC"),
+			Vendor:     cell("Vendor"),
+			Dynamic:    cell("Type") == "dynamic",
+			Status:     cell("Status"),
+			Server:     cell("Server"),
+			Port:       cell("Port"),
+			Comment:    cell("Comment"),
+		}
+		lease.Hostname, lease.HostnameFromDNS = strings.CutSuffix(cell("Hostname"), " (dns)")
+		if expires := cell("Expires"); expires != "" && expires != "-" {
+			lease.Expiry, _ = routeros.ParseDuration(expires)
+		}
+		if t, err := time.ParseInLocation("2006-01-02 15:04", cell("Last Seen"), time.Local); err == nil {
+			lease.LastSeen = &t
+		}
+		leases = append(leases, lease)
+	}
+	return leases, nil
+}
+
+// leaseDiff is the result of comparing two lease lists by MAC
+type leaseDiff struct {
+	added, removed []routeros.DHCPLease
+	changed        [][2]routeros.DHCPLease // old, new
+}
+
+// diffLeases compares old and current leases keyed by MAC. Address, hostname
+// and type changes count as changed.
+func diffLeases(old, current []routeros.DHCPLease) leaseDiff {
+	byMAC := func(leases []routeros.DHCPLease) map[string]routeros.DHCPLease {
+		m := make(map[string]routeros.DHCPLease, len(leases))
+		for _, lease := range leases {
+			m[macvendor.MACKey(lease.MacAddress)] = lease
+		}
+		return m
+	}
+	before, after := byMAC(old), byMAC(current)
+
+	var d leaseDiff
+	for mac, n := range after {
+		o, ok := before[mac]
+		switch {
+		case !ok:
+			d.added = append(d.added, n)
+		case o.Address != n.Address || o.Hostname != n.Hostname || leaseType(o) != leaseType(n):
+			d.changed = append(d.changed, [2]routeros.DHCPLease{o, n})
+		}
+	}
+	for mac, o := range before {
+		if _, ok := after[mac]; !ok {
+			d.removed = append(d.removed, o)
+		}
+	}
+
+	macOrder := func(a, b routeros.DHCPLease) int { return strings.Compare(a.MacAddress, b.MacAddress) }
+	slices.SortFunc(d.added, macOrder)
+	slices.SortFunc(d.removed, macOrder)
+	slices.SortFunc(d.changed, func(a, b [2]routeros.DHCPLease) int { return macOrder(a[1], b[1]) })
+	return d
+}
+
+// runDiff prints the differences between two lease files and returns the
+// exit status: 0 when identical, 1 when they differ and 2 on errors, like
+// diff(1).
+func runDiff(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: -diff <old.json> <new.json>")
+		return 2
+	}
+	old, err := loadLeaseFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", args[0], err)
+		return 2
+	}
+	current, err := loadLeaseFile(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", args[1], err)
+		return 2
+	}
+
+	d := diffLeases(old, current)
+	addedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	removedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+	changedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Bold(true)
+
+	describe := func(l routeros.DHCPLease) string {
+		return fmt.Sprintf("%s %s %s", l.MacAddress, l.Address, cmp.Or(l.Hostname, "-"))
+	}
+	for _, l := range d.added {
+		fmt.Println(addedStyle.Render("+ " + describe(l)))
+	}
+	for _, l := range d.removed {
+		fmt.Println(removedStyle.Render("- " + describe(l)))
+	}
+	for _, pair := range d.changed {
+		o, n := pair[0], pair[1]
+		var changes []string
+		if o.Address != n.Address {
+			changes = append(changes, changedStyle.Render(fmt.Sprintf("IP %s -> %s", o.Address, n.Address)))
+		}
+		if o.Hostname != n.Hostname {
+			changes = append(changes, changedStyle.Render(fmt.Sprintf("hostname %q -> %q", o.Hostname, n.Hostname)))
+		}
+		if leaseType(o) != leaseType(n) {
+			changes = append(changes, changedStyle.Render(fmt.Sprintf("type %s -> %s", leaseType(o), leaseType(n))))
+		}
+		fmt.Printf("~ %s %s\n", n.MacAddress, strings.Join(changes, ", "))
+	}
+
+	if len(d.added)+len(d.removed)+len(d.changed) == 0 {
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "%d added, %d removed, %d changed\n", len(d.added), len(d.removed), len(d.changed))
+	return 1
+}
+
+// sourceAddress returns the address of the router behind source
+func sourceAddress(source LeaseSource, router *RouterConnection) string {
+	if router != nil {
+		return router.address
+	}
+	switch source := source.(type) {
+	case *RouterConnection:
+		return source.address
+	case *restLeaseSource:
+		return strings.TrimPrefix(source.baseURL, "https://")
+	case *apiLeaseSource:
+		return source.address
+	case fileLeaseSource:
+		return source.path
+	}
+	return ""
+}
+
+// printMetrics writes lease counts, and pool utilization over SSH, in the
+// Prometheus text exposition format.
+func printMetrics(w io.Writer, source LeaseSource, router *RouterConnection) error {
+	leases, err := fetchLeases(source)
+	if err != nil {
+		return err
+	}
+	var pools []routeros.PoolUsage
+	if router != nil {
+		if pools, err = fetchPoolUsage(router); err != nil {
+			return err
+		}
+	}
+
+	byVendor := make(map[string]int)
+	byType := map[string]int{"static": 0, "dynamic": 0}
+	for _, lease := range leases {
+		byVendor[cmp.Or(lease.Vendor, "Unknown")]++
+		byType[leaseType(lease)]++
+	}
+
+	var b strings.Builder
+	metric := func(name, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+	sample := func(name, label, value string, n int) {
+		if label == "" {
+			fmt.Fprintf(&b, "%s %d\n", name, n)
+			return
+		}
+		fmt.Fprintf(&b, "%s{%s=\"%s\"} %d\n", name, label, promEscape(value), n)
+	}
+
+	metric("routeros_dhcp_leases", "Number of DHCP leases.")
+	sample("routeros_dhcp_leases", "", "", len(leases))
+
+	metric("routeros_dhcp_leases_by_type", "Number of DHCP leases by static or dynamic type.")
+	for _, t := range []string{"dynamic", "static"} {
+		sample("routeros_dhcp_leases_by_type", "type", t, byType[t])
+	}
+
+	metric("routeros_dhcp_leases_by_vendor", "Number of DHCP leases by MAC vendor.")
+	vendors := make([]string, 0, len(byVendor))
+	for vendor := range byVendor {
+		vendors = append(vendors, vendor)
+	}
+	sort.Strings(vendors)
+	for _, vendor := range vendors {
+		sample("routeros_dhcp_leases_by_vendor", "vendor", vendor, byVendor[vendor])
+	}
+
+	if len(pools) > 0 {
+		metric("routeros_ip_pool_size", "Number of addresses in the IP pool.")
+		for _, pool := range pools {
+			sample("routeros_ip_pool_size", "pool", pool.Name, pool.Size)
+		}
+		metric("routeros_ip_pool_used", "Number of IP pool addresses held by DHCP leases.")
+		for _, pool := range pools {
+			sample("routeros_ip_pool_used", "pool", pool.Name, pool.Used)
+		}
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// promEscape escapes a Prometheus label value
+func promEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// htmlReport is a standalone lease report page. The table sorts on header
+// clicks without any external assets.
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>DHCP leases - {{.Router}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; margin-bottom: 0; }
+p.meta { color: #666; margin-top: .3em; }
+table { border-collapse: collapse; width: 100%; font-size: .9em; }
+th, td { text-align: left; padding: .35em .7em; border-bottom: 1px solid #ddd; }
+th { background: #f4f4f4; cursor: pointer; user-select: none; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+tr:hover td { background: #fafafa; }
+td.error { color: #b00; }
+</style>
+</head>
+<body>
+<h1>DHCP leases on {{.Router}}</h1>
+<p class="meta">Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}} &middot; {{len .Leases}} leases</p>
+<table id="leases">
+<thead><tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Leases}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+<script>
+document.querySelectorAll("#leases th").forEach(function (th, col) {
+  th.addEventListener("click", function () {
+    var asc = !th.classList.contains("asc");
+    document.querySelectorAll("#leases th").forEach(function (h) { h.className = ""; });
+    th.className = asc ? "asc" : "desc";
+    var body = document.querySelector("#leases tbody");
+    var rows = Array.prototype.slice.call(body.rows);
+    var key = function (row) {
+      var text = row.cells[col].textContent;
+      var ip = text.match(/^(\d+)\.(\d+)\.(\d+)\.(\d+)$/);
+      return ip ? ip.slice(1).map(function (n) { return ("00" + n).slice(-3); }).join(".") : text.toLowerCase();
+    };
+    rows.sort(function (a, b) {
+      var x = key(a), y = key(b);
+      return (x < y ? -1 : x > y ? 1 : 0) * (asc ? 1 : -1);
+    });
+    rows.forEach(function (row) { body.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`))
+
+// unresolvedOUI is an OUI whose vendor lookup failed, with the number of
+// leases affected and one of their MACs.
+type unresolvedOUI struct {
+	oui     string
+	result  string // "Unknown" or "Rate Limited"
+	devices int
+	example string
+}
+
+// unresolvedVendors lists the distinct OUIs of leases left without a
+// vendor, most affected devices first. Leases with malformed MACs are left
+// out, since no lookup was made for them.
+func unresolvedVendors(leases []routeros.DHCPLease) []unresolvedOUI {
+	byOUI := make(map[string]*unresolvedOUI)
+	for _, lease := range leases {
+		switch lease.Vendor {
+		case "", "Unknown", "Rate Limited":
+		default:
+			continue
+		}
+		oui, err := macvendor.OUI(lease.MacAddress)
+		if err != nil {
+			continue
+		}
+		entry, ok := byOUI[oui]
+		if !ok {
+			entry = &unresolvedOUI{oui: oui, result: cmp.Or(lease.Vendor, "Unknown"), example: lease.MacAddress}
+			byOUI[oui] = entry
+		}
+		entry.devices++
+	}
+
+	var list []unresolvedOUI
+	for _, entry := range byOUI {
+		list = append(list, *entry)
+	}
+	slices.SortFunc(list, func(a, b unresolvedOUI) int {
+		return cmp.Or(cmp.Compare(b.devices, a.devices), strings.Compare(a.oui, b.oui))
+	})
+	return list
+}
+
+var unresolvedColumns = []table.Column{
+	{Title: "OUI"},
+	{Title: "Devices"},
+	{Title: "Result"},
+	{Title: "Example MAC"},
+}
+
+// writeUnresolvedReport writes the OUIs whose vendor couldn't be resolved
+// to path, or stdout for "-", as a worklist for vendor_overrides.json.
+func writeUnresolvedReport(path string, source LeaseSource) error {
+	leases, err := fetchLeases(source)
+	if err != nil {
+		return err
+	}
+	list := unresolvedVendors(leases)
+
+	var rows []table.Row
+	for _, entry := range list {
+		rows = append(rows, table.Row{entry.oui, strconv.Itoa(entry.devices), entry.result, entry.example})
+	}
+	var b bytes.Buffer
+	if err := tui.PrintPlain(&b, unresolvedColumns, rows, nil); err != nil {
+		return err
+	}
+
+	if path == "-" {
+		_, err = os.Stdout.Write(b.Bytes())
+	} else {
+		err = os.WriteFile(path, b.Bytes(), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	if len(list) > 0 {
+		fmt.Fprintf(os.Stderr, "%d OUIs without a vendor; label them in vendor_overrides.json\n", len(list))
+	} else {
+		fmt.Fprintln(os.Stderr, "Every vendor was resolved")
+	}
+	return nil
+}
+
+// writeHTMLReport writes the enriched leases to path as a standalone,
+// sortable HTML page.
+func writeHTMLReport(path string, source LeaseSource, router *RouterConnection) error {
+	leases, err := fetchLeases(source)
+	if err != nil {
+		return err
+	}
+
+	selected, rows := selectLeaseColumns(leaseRows(leases))
+	var columns []string
+	for _, col := range selected {
+		columns = append(columns, col.Title)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = htmlReport.Execute(f, struct {
+		Router    string
+		Generated time.Time
+		Columns   []string
+		Leases    []table.Row
+	}{sourceAddress(source, router), time.Now(), columns, rows})
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeSnapshot runs every collector and writes the combined result to path.
+// Individual collector errors are recorded in the snapshot rather than
+// aborting it.
+func writeSnapshot(path string, source LeaseSource, router *RouterConnection) error {
+	snap := Snapshot{Timestamp: time.Now(), Router: sourceAddress(source, router)}
+
+	record := func(what string, err error) {
+		snap.Errors = append(snap.Errors, fmt.Sprintf("%s: %v", what, err))
+	}
+
+	var err error
+	if snap.Leases, err = fetchLeases(source); err != nil {
+		record("leases", err)
+	}
+
+	if router == nil {
+		record("arp, interfaces, system", fmt.Errorf("requires the SSH transport"))
+	} else {
+		if snap.ARP, err = fetchARP(router); err != nil {
+			record("arp", err)
+		}
+		if snap.Interfaces, err = fetchInterfaceStats(router); err != nil {
+			record("interfaces", err)
+		}
+		if res, err := fetchSystemResource(router); err != nil {
+			record("system", err)
+		} else {
+			snap.System = &res
+		}
+	}
+
+	data, err := json.MarshalIndent(snap, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func fetchInterfaceStats(runner CommandRunner) ([]routeros.InterfaceStat, error) {
+	output, err := runner.Run(interfaceCommand)
+	if err != nil {
+		return nil, err
+	}
+	return routeros.ParseInterfaceStats(string(output)), nil
+}
+
+var interfaceColumns = []table.Column{
+	{Title: "Interface", Width: 20},
+	{Title: "Rx Bytes", Width: 12},
+	{Title: "Tx Bytes", Width: 12},
+	{Title: "Rx Packets", Width: 12},
+	{Title: "Tx Packets", Width: 12},
+}
+
+func interfaceRows(stats []routeros.InterfaceStat) []table.Row {
+	var rows []table.Row
+	for _, stat := range stats {
+		rows = append(rows, table.Row{
+			stat.Name,
+			routeros.FormatBytes(stat.RxBytes),
+			routeros.FormatBytes(stat.TxBytes),
+			strconv.FormatUint(stat.RxPackets, 10),
+			strconv.FormatUint(stat.TxPackets, 10),
+		})
+	}
+	return rows
+}
+
+func viewInterfaceStats(runner CommandRunner) {
+	stats, err := fetchInterfaceStats(runner)
+	if err != nil {
+		fmt.Printf("Error fetching interface statistics: %v\n", err)
+		return
+	}
+
+	tui.PrintTable(tui.Table{
+		Name:    "interfaces",
+		Columns: interfaceColumns,
+		Rows:    interfaceRows(stats),
+		Fetch: func() ([]table.Row, error) {
+			stats, err := fetchInterfaceStats(runner)
+			return interfaceRows(stats), err
+		},
+	})
+}
+
+// connectionFilter narrows the connection tracking table client-side
+type connectionFilter struct {
+	src   string
+	dst   string
+	limit int
+}
+
+// fetchConnections retrieves the connection tracking table, returning the
+// entries matching the filter and the total number of connections.
+func fetchConnections(runner CommandRunner, filter connectionFilter) ([]routeros.Connection, int, error) {
+	output, err := runner.Run(connectionCommand)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	all := routeros.ParseConnections(string(output))
+	var conns []routeros.Connection
+	for _, conn := range all {
+		if !strings.Contains(conn.SrcAddress, filter.src) || !strings.Contains(conn.DstAddress, filter.dst) {
+			continue
+		}
+		if filter.limit > 0 && len(conns) >= filter.limit {
+			break
+		}
+		conns = append(conns, conn)
+	}
+	return conns, len(all), nil
+}
+
+var connectionColumns = []table.Column{
+	{Title: "Protocol", Width: 8},
+	{Title: "Source", Width: 22},
+	{Title: "Destination", Width: 22},
+	{Title: "State", Width: 12},
+}
+
+func connectionRows(conns []routeros.Connection) []table.Row {
+	var rows []table.Row
+	for _, conn := range conns {
+		rows = append(rows, table.Row{
+			conn.Protocol,
+			conn.SrcAddress,
+			conn.DstAddress,
+			conn.State,
+		})
+	}
+	return rows
+}
+
+func viewConnections(runner CommandRunner) {
+	filter := connectionFilter{
+		src:   readInput("Source address filter (blank for all): "),
+		dst:   readInput("Destination address filter (blank for all): "),
+		limit: defaultConnectionLimit,
+	}
+	if input := readInput(fmt.Sprintf("Row limit [%d]: ", filter.limit)); input != "" {
+		limit, err := strconv.Atoi(input)
+		if err != nil || limit < 0 {
+			fmt.Printf("Invalid row limit: %s\n", input)
+			return
+		}
+		filter.limit = limit
+	}
+
+	conns, total, err := fetchConnections(runner, filter)
+	if err != nil {
+		fmt.Printf("Error fetching connections: %v\n", err)
+		return
+	}
+	fmt.Printf("Showing %d of %d connections\n", len(conns), total)
+
+	tui.PrintTable(tui.Table{
+		Name:    "connections",
+		Columns: connectionColumns,
+		Rows:    connectionRows(conns),
+		Fetch: func() ([]table.Row, error) {
+			conns, _, err := fetchConnections(runner, filter)
+			return connectionRows(conns), err
+		},
+	})
+}
+
+// seenMu serializes updates to the last-seen store
+var seenMu sync.Mutex
+
+func loadSeen() map[string]time.Time {
+	seen := make(map[string]time.Time)
+	data, err := config.ReadFile("seen.json")
+	if err != nil {
+		return seen
+	}
+	if err := json.Unmarshal(data, &seen); err != nil {
+		return make(map[string]time.Time)
+	}
+	return seen
+}
+
+func saveSeen(seen map[string]time.Time) error {
+	data, err := json.MarshalIndent(seen, "", "    ")
+	if err != nil {
+		return err
+	}
+	return config.WriteFile("seen.json", data)
+}
+
+// recordSeen marks macs as present now in the last-seen store and returns
+// the updated store, keyed by uppercase MAC.
+func recordSeen(macs []string) map[string]time.Time {
+	seenMu.Lock()
+	defer seenMu.Unlock()
+
+	if len(macs) == 0 {
+		return loadSeen()
+	}
+	var seen map[string]time.Time
+	mark := func() {
+		seen = loadSeen()
+		now := time.Now()
+		for _, mac := range macs {
+			seen[macvendor.MACKey(mac)] = now
+		}
+	}
+	err := config.Update("seen.json", func() {
+		mark()
+		if err := saveSeen(seen); err != nil {
+			slog.Debug("failed to save last-seen store", "error", err)
+		}
+	})
+	if err != nil {
+		// Show this run's sightings even though they weren't saved
+		slog.Debug("failed to lock last-seen store", "error", err)
+		mark()
+	}
+	return seen
+}
+
+// formatLastSeen renders a last-seen time so that it sorts as text, or
+// "-" for MACs never seen present.
+func formatLastSeen(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// leaseType describes how a lease was assigned
+func leaseType(lease routeros.DHCPLease) string {
+	if lease.Dynamic {
+		return "dynamic"
+	}
+	return "static"
+}
+
+// leaseRows converts leases to table rows.
+func leaseRows(leases []routeros.DHCPLease) []table.Row {
+	var rows []table.Row
+	for _, lease := range leases {
+		rows = append(rows, table.Row{
+			lease.Address,
+			lease.MacAddress,
+			leaseHostname(lease),
+			lease.Vendor,
+			routeros.FormatExpiry(lease.Expiry),
+			leaseType(lease),
+			lease.Status,
+			lease.Server,
+			lease.Port,
+			lease.Comment,
+			formatLastSeen(lease.LastSeen),
+			lease.ID,
+		})
+	}
+	return rows
+}
+
+// leaseHostname marks names found through DNS so they can be told apart
+// from names the DHCP client sent.
+func leaseHostname(lease routeros.DHCPLease) string {
+	if lease.HostnameFromDNS {
+		return lease.Hostname + " (dns)"
+	}
+	return lease.Hostname
+}
+
+var leaseColumns = []table.Column{
+	{Title: "IP", Width: 15},
+	{Title: "MAC", Width: 17},
+	{Title: "Hostname", Width: 20},
+	{Title: "Vendor", Width: 30},
+	{Title: "Expires", Width: 12},
+	{Title: "Type", Width: 8},
+	{Title: "Status", Width: 10},
+	{Title: "Server", Width: 12},
+	{Title: "Port", Width: 10},
+	{Title: "Comment", Width: 20},
+	{Title: "Last Seen", Width: 16},
+	{Title: "ID", Width: 6},
+}
+
+// selectedFields holds the lease column titles chosen with -fields, in
+// order, or nil for all of them.
+var selectedFields []string
+
+// fieldName is the -fields name of a column title, e.g. "last-seen"
+func fieldName(title string) string {
+	return strings.ReplaceAll(strings.ToLower(title), " ", "-")
+}
+
+// parseFields resolves comma-separated -fields names to lease column
+// titles, rejecting unknown and repeated names.
+func parseFields(list string) ([]string, error) {
+	var known []string
+	for _, col := range leaseColumns {
+		known = append(known, fieldName(col.Title))
+	}
+
+	var titles []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		i := slices.Index(known, name)
+		if i < 0 {
+			return nil, fmt.Errorf("unknown field %q (known fields: %s)", name, strings.Join(known, ", "))
+		}
+		if slices.Contains(titles, leaseColumns[i].Title) {
+			return nil, fmt.Errorf("field %q given twice", name)
+		}
+		titles = append(titles, leaseColumns[i].Title)
+	}
+	return titles, nil
+}
+
+// orderLeaseColumns puts the -fields columns first, in the order given,
+// followed by the rest, and reorders the cells of rows to match. The
+// viewer hides the rest but still filters on them.
+func orderLeaseColumns(rows []table.Row) ([]table.Column, []table.Row) {
+	if selectedFields == nil {
+		return leaseColumns, rows
+	}
+	var order []int
+	for _, title := range selectedFields {
+		order = append(order, slices.IndexFunc(leaseColumns, func(col table.Column) bool { return col.Title == title }))
+	}
+	for i, col := range leaseColumns {
+		if !slices.Contains(selectedFields, col.Title) {
+			order = append(order, i)
+		}
+	}
+
+	columns := make([]table.Column, len(order))
+	for j, i := range order {
+		columns[j] = leaseColumns[i]
+	}
+	ordered := make([]table.Row, len(rows))
+	for r, row := range rows {
+		cells := make(table.Row, len(order))
+		for j, i := range order {
+			cells[j] = row[i]
+		}
+		ordered[r] = cells
+	}
+	return columns, ordered
+}
+
+// selectLeaseColumns keeps only the -fields columns, in order, for exports
+func selectLeaseColumns(rows []table.Row) ([]table.Column, []table.Row) {
+	columns, rows := orderLeaseColumns(rows)
+	if selectedFields == nil {
+		return columns, rows
+	}
+	n := len(selectedFields)
+	for r := range rows {
+		rows[r] = rows[r][:n]
+	}
+	return columns[:n], rows
+}
+
+// defaultHiddenColumns start hidden; press c to show them
+var defaultHiddenColumns = []string{"ID"}
+
+// hiddenLeaseColumns lists the lease columns to hide: the defaults, or
+// every column not chosen with -fields.
+func hiddenLeaseColumns() []string {
+	if selectedFields == nil {
+		return defaultHiddenColumns
+	}
+	var hidden []string
+	for _, col := range leaseColumns {
+		if !slices.Contains(selectedFields, col.Title) {
+			hidden = append(hidden, col.Title)
+		}
+	}
+	return hidden
+}
+
+// leaseActions are the management actions offered in the lease viewer
+// when connected over SSH.
+func leaseActions(runner CommandRunner) []tui.Action {
+	return []tui.Action{{
+		Binding: key.NewBinding(key.WithKeys("S"), key.WithHelp("S", "make lease static")),
+		Prompts: func(m tui.Model, row table.Row) ([]string, error) {
+			ip := row[m.ColumnIndex("IP")]
+			if row[m.ColumnIndex("Type")] == "static" {
+				return nil, fmt.Errorf("%s is already static", ip)
+			}
+			return []string{fmt.Sprintf("Make the lease for %s static?", ip)}, nil
+		},
+		Run: func(m tui.Model, row table.Row) (string, error) {
+			output, err := runner.Run("/ip dhcp-server lease make-static " + leaseSelector(m, row))
+			return strings.TrimSpace(string(output)), err
+		},
+	}, {
+		Binding: key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "remove lease")),
+		Prompts: func(m tui.Model, row table.Row) ([]string, error) {
+			ip := row[m.ColumnIndex("IP")]
+			prompts := []string{fmt.Sprintf("Remove the lease for %s?", ip)}
+			if row[m.ColumnIndex("Type")] == "static" {
+				// Reservations are usually deliberate, so ask twice
+				prompts = append(prompts, fmt.Sprintf("%s is a static lease and its reservation will be lost. Remove it anyway?", ip))
+			}
+			return prompts, nil
+		},
+		Run: func(m tui.Model, row table.Row) (string, error) {
+			output, err := runner.Run("/ip dhcp-server lease remove " + leaseSelector(m, row))
+			return strings.TrimSpace(string(output)), err
+		},
+	}}
+}
+
+// leaseSelector identifies the lease in row for a RouterOS command: its .id
+// when known, otherwise a find on its address and MAC.
+func leaseSelector(m tui.Model, row table.Row) string {
+	if id := row[m.ColumnIndex("ID")]; id != "" {
+		return id
+	}
+	return fmt.Sprintf("[find address=%s mac-address=%s]",
+		routeros.Quote(row[m.ColumnIndex("IP")]), routeros.Quote(row[m.ColumnIndex("MAC")]))
+}
+
+// RouterInfo identifies the connected router
+type RouterInfo struct {
+	Identity  string
+	BoardName string
+	Version   string
+}
+
+// label describes the router for viewer headers, e.g.
+// "core-rtr (RB5009UG+S+, RouterOS 7.14.3) at 192.168.88.1"
+func (info RouterInfo) label(address string) string {
+	var details []string
+	if info.BoardName != "" {
+		details = append(details, info.BoardName)
+	}
+	if info.Version != "" {
+		details = append(details, "RouterOS "+info.Version)
+	}
+	label := cmp.Or(info.Identity, address)
+	if len(details) > 0 {
+		label += " (" + strings.Join(details, ", ") + ")"
+	}
+	if info.Identity != "" {
+		label += " at " + address
+	}
+	return label
+}
+
+func fetchRouterInfo(runner CommandRunner) (RouterInfo, error) {
+	var info RouterInfo
+	output, err := runner.Run(identityCommand)
+	if err != nil {
+		return info, err
+	}
+	info.Identity = routeros.ParseKeyValues(string(output))["name"]
+
+	output, err = runner.Run(resourceCommand)
+	if err != nil {
+		return info, err
+	}
+	fields := routeros.ParseKeyValues(string(output))
+	info.BoardName = fields["board-name"]
+	info.Version = fields["version"]
+	return info, nil
+}
+
+// testConnection checks that the router answers an authenticated request
+// and prints what it found. REST sources are checked by listing leases.
+func testConnection(source LeaseSource, router *RouterConnection, start time.Time) error {
+	if router == nil {
+		leases, err := source.Leases()
+		if err != nil {
+			return err
+		}
+		api := "REST API"
+		if _, ok := source.(*apiLeaseSource); ok {
+			api = "RouterOS API"
+		}
+		fmt.Printf("OK: %s at %s answered with %d leases in %v\n",
+			api, sourceAddress(source, nil), len(leases), time.Since(start).Round(time.Millisecond))
+		return nil
+	}
+
+	info, err := fetchRouterInfo(router)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("OK: %s is %q (%s, RouterOS %s), answered in %v\n",
+		router.address, info.Identity, info.BoardName, info.Version, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// fetchSystemResource collects CPU, memory and health readings
+func fetchSystemResource(runner CommandRunner) (routeros.SystemResource, error) {
+	var res routeros.SystemResource
+
+	output, err := runner.Run(resourceCommand)
+	if err != nil {
+		return res, err
+	}
+	fields := routeros.ParseKeyValues(string(output))
+	res.BoardName = fields["board-name"]
+	res.Version = fields["version"]
+	res.Uptime = fields["uptime"]
+	res.CPULoad, _ = strconv.Atoi(strings.TrimSuffix(fields["cpu-load"], "%"))
+	if free, ok := routeros.ParseBytes(fields["free-memory"]); ok {
+		res.FreeMemory = uint64(free)
+	}
+	if total, ok := routeros.ParseBytes(fields["total-memory"]); ok {
+		res.TotalMemory = uint64(total)
+	}
+
+	// Boards without sensors may reject the health command entirely
+	output, err = runner.Run(healthCommand)
+	if err != nil {
+		return res, nil
+	}
+	health := routeros.ParseHealth(string(output))
+	if v, ok := health["temperature"]; ok {
+		res.Temperature = &v
+	}
+	if v, ok := health["voltage"]; ok {
+		res.Voltage = &v
+	}
+	return res, nil
+}
+
+// dashboardTickMsg triggers a dashboard refresh
+type dashboardTickMsg time.Time
+
+// resourceMsg carries the result of a background dashboard refresh
+type resourceMsg struct {
+	resource routeros.SystemResource
+	err      error
+}
+
+// dashboardModel renders the system resource panel
+type dashboardModel struct {
+	runner     CommandRunner
+	resource   routeros.SystemResource
+	err        error
+	interval   time.Duration
+	refreshing bool
+	updated    time.Time
+	status     string
+}
+
+func (m dashboardModel) refresh() tea.Cmd {
+	runner := m.runner
+	return func() tea.Msg {
+		res, err := fetchSystemResource(runner)
+		return resourceMsg{resource: res, err: err}
+	}
+}
+
+func (m dashboardModel) tick() tea.Cmd {
+	return tea.Tick(m.interval, func(t time.Time) tea.Msg {
+		return dashboardTickMsg(t)
+	})
+}
+
+// Init implements tea.Model
+func (m dashboardModel) Init() tea.Cmd {
+	return m.tick()
+}
+
+// Update implements tea.Model
+func (m dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			return m, tea.Quit
+		case "r":
+			if !m.refreshing {
+				m.refreshing = true
+				return m, m.refresh()
+			}
+		}
+	case dashboardTickMsg:
+		if m.refreshing {
+			return m, m.tick()
+		}
+		m.refreshing = true
+		return m, tea.Batch(m.tick(), m.refresh())
+	case tui.StatusMsg:
+		m.status = string(msg)
+	case resourceMsg:
+		m.refreshing = false
+		m.err = msg.err
+		if msg.err == nil {
+			m.resource = msg.resource
+			m.updated = time.Now()
+		}
+	}
+	return m, nil
+}
+
+// View implements tea.Model
+func (m dashboardModel) View() string {
+	footer := fmt.Sprintf("Updated %s, refreshing every %v (r to refresh now, q to quit)",
+		m.updated.Format("15:04:05"), m.interval)
+	if m.err != nil {
+		footer = tui.WarningText(fmt.Sprintf("Refresh failed: %v", m.err)) + "\n" + footer
+	}
+	if m.status != "" {
+		footer += "\n" + m.status
+	}
+
+	return "\n" + tui.RouterHeader() + "System Resources\n\n" + m.panel() + "\n\n" + footer
+}
+
+// panel renders the resource readings, highlighting those past their
+// warning thresholds
+func (m dashboardModel) panel() string {
+	label := lipgloss.NewStyle().Width(14).Foreground(lipgloss.Color("240"))
+
+	res := m.resource
+	line := func(name, value string, warning bool) string {
+		if warning {
+			value = tui.WarningText(value)
+		}
+		return label.Render(name) + value
+	}
+
+	lines := []string{
+		line("Board", res.BoardName, false),
+		line("Version", res.Version, false),
+		line("Uptime", res.Uptime, false),
+		line("CPU Load", fmt.Sprintf("%d%%", res.CPULoad), res.CPULoad >= cpuLoadWarning),
+	}
+
+	lowMemory := res.TotalMemory > 0 && res.FreeMemory*100/res.TotalMemory < freeMemoryWarning
+	lines = append(lines, line("Memory", fmt.Sprintf("%s free of %s",
+		routeros.FormatBytes(res.FreeMemory), routeros.FormatBytes(res.TotalMemory)), lowMemory))
+
+	if res.Temperature != nil {
+		lines = append(lines, line("Temperature", fmt.Sprintf("%.0f°C", *res.Temperature), *res.Temperature >= temperatureWarning))
+	} else {
+		lines = append(lines, line("Temperature", "n/a", false))
+	}
+	if res.Voltage != nil {
+		lines = append(lines, line("Voltage", fmt.Sprintf("%.1fV", *res.Voltage), *res.Voltage < minVoltageWarning))
+	} else {
+		lines = append(lines, line("Voltage", "n/a", false))
+	}
+
+	return lipgloss.NewStyle().
+		Border(tui.Border(lipgloss.RoundedBorder())).
+		BorderForeground(lipgloss.Color("240")).
+		Padding(0, 1).
+		Render(strings.Join(lines, "\n"))
+}
+
+func viewSystemResources(runner CommandRunner) {
+	res, err := fetchSystemResource(runner)
+	if err != nil {
+		fmt.Printf("Error fetching system resources: %v\n", err)
+		return
+	}
+
+	m := dashboardModel{
+		runner:   runner,
+		resource: res,
+		interval: defaultDashboardInterval,
+		updated:  time.Now(),
+	}
+	if *watchFlag > 0 {
+		m.interval = *watchFlag
+	}
+	if !tui.Interactive() {
+		fmt.Println(m.panel())
+		return
+	}
+
+	p := tea.NewProgram(m)
+	defer tui.RedirectStatus(p)()
+	if _, err := tui.RunProgram(p); err != nil {
+		fmt.Printf("Error running program: %v", err)
+	}
 }
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ezeql/routeros-misc-tools/internal/routeros"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// cannedRunner answers each command with recorded router output
+type cannedRunner map[string]string
+
+// Run implements CommandRunner
+func (c cannedRunner) Run(cmd string) ([]byte, error) {
+	output, ok := c[cmd]
+	if !ok {
+		return nil, fmt.Errorf("unexpected command %q", cmd)
+	}
+	return []byte(output), nil
+}
+
+var cannedRouter = cannedRunner{
+	leaseCommand: ` 0 D address=192.168.88.250 mac-address=B8:27:EB:12:34:56 server=defconf status=bound expires-after=8m12s host-name=raspberrypi
+ 1   address=192.168.88.10 mac-address=00:11:32:AA:BB:CC server=defconf status=bound host-name=DiskStation
+ 2 X address=192.168.88.30 mac-address=3C:22:FB:AA:00:01 server=defconf status=waiting
+`,
+	leaseIDCommand: `id=*1 address=192.168.88.250 mac-address=B8:27:EB:12:34:56
+id=*2 address=192.168.88.10 mac-address=00:11:32:aa:bb:cc
+`,
+	arpCommand: ` 0 D address=192.168.88.250 mac-address=B8:27:EB:12:34:56 interface=bridge
+ 1   address=192.168.88.5 mac-address=F0:9F:C2:00:00:01 interface=bridge
+`,
+	poolCommand: ` 0 name=dhcp ranges=192.168.88.10-192.168.88.254
+ 1 name=guest ranges=10.0.20.0/24
+`,
+}
+
+func TestListLeases(t *testing.T) {
+	leases, err := listLeases(cannedRouter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ address, id string }{
+		{"192.168.88.250", "*1"},
+		{"192.168.88.10", "*2"}, // matched despite the lowercase MAC
+		{"192.168.88.30", ""},
+	}
+	if len(leases) != len(want) {
+		t.Fatalf("got %d leases, want %d", len(leases), len(want))
+	}
+	for i, w := range want {
+		if leases[i].Address != w.address || leases[i].ID != w.id {
+			t.Errorf("lease %d = %s %q, want %s %q", i, leases[i].Address, leases[i].ID, w.address, w.id)
+		}
+	}
+}
+
+func TestFetchARP(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	*noVendorFlag = true
+	t.Cleanup(func() { *noVendorFlag = false })
+
+	entries, err := fetchARP(cannedRouter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d ARP entries, want 2", len(entries))
+	}
+	if !entries[0].HasLease || entries[1].HasLease {
+		t.Errorf("HasLease = %v, %v; want true, false", entries[0].HasLease, entries[1].HasLease)
+	}
+
+	if _, err := fetchARP(cannedRunner{}); err == nil {
+		t.Error("fetchARP succeeded without ARP output")
+	}
+}
+
+func TestFetchPoolUsage(t *testing.T) {
+	pools, err := fetchPoolUsage(cannedRouter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The disabled lease doesn't count as used
+	want := []routeros.PoolUsage{
+		{Name: "dhcp", Ranges: "192.168.88.10-192.168.88.254", Size: 245, Used: 2},
+		{Name: "guest", Ranges: "10.0.20.0/24", Size: 256, Used: 0},
+	}
+	if len(pools) != len(want) {
+		t.Fatalf("got %d pools, want %d", len(pools), len(want))
+	}
+	for i := range want {
+		if pools[i] != want[i] {
+			t.Errorf("pool %d = %+v, want %+v", i, pools[i], want[i])
+		}
+	}
+}
+
+func TestHostKeyAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPub, err := ssh.NewPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, err := ssh.NewPublicKey(edPubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	lines := []string{
+		knownhosts.Line([]string{"192.168.88.1"}, rsaPub),
+		knownhosts.Line([]string{"router.lan:2222"}, edPub),
+		knownhosts.Line([]string{"router.lan:2222"}, rsaPub),
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		address string
+		want    []string
+	}{
+		{"192.168.88.1:22", []string{ssh.KeyAlgoRSA, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512}},
+		{"router.lan:2222", []string{ssh.KeyAlgoED25519, ssh.KeyAlgoRSA, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSASHA512}},
+		{"192.168.88.2:22", nil},
+	}
+	for _, tt := range tests {
+		got := hostKeyAlgorithms(path, tt.address)
+		slices.Sort(tt.want)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("hostKeyAlgorithms(%q) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestRememberCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		prompted bool
+		want     bool
+	}{
+		{"prompted", "", true, true},
+		{"flags only", "", false, false},
+		{"address from environment", "ROUTEROS_IP", true, false},
+		{"user from environment", "ROUTEROS_USER", true, false},
+		{"password from environment", "ROUTEROS_PASSWORD", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range []string{"ROUTEROS_IP", "ROUTEROS_USER", "ROUTEROS_PASSWORD"} {
+				t.Setenv(env, "")
+			}
+			if tt.env != "" {
+				t.Setenv(tt.env, "value")
+			}
+			if got := rememberCredentials(tt.prompted); got != tt.want {
+				t.Errorf("rememberCredentials(%v) = %v, want %v", tt.prompted, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArpPresent(t *testing.T) {
+	output := ` 0 D address=192.168.88.250 mac-address=B8:27:EB:12:34:56 interface=bridge status=reachable
+ 1 D address=192.168.88.251 interface=bridge status=incomplete
+ 2 D address=192.168.88.252 mac-address=00:00:00:00:00:00 interface=bridge status=failed
+ 3 I address=192.168.88.253 mac-address=F0:9F:C2:00:00:01 interface=bridge
+ 4 DC address=192.168.88.254 mac-address=F0:9F:C2:00:00:02 interface=bridge
+ 5   address=192.168.88.5 mac-address=00:00:00:00:00:00 interface=bridge
+`
+	want := []bool{true, false, false, false, true, false}
+
+	entries := routeros.ParseARP(output)
+	if len(entries) != len(want) {
+		t.Fatalf("got %d ARP entries, want %d", len(entries), len(want))
+	}
+	for i, entry := range entries {
+		if got := arpPresent(entry); got != want[i] {
+			t.Errorf("arpPresent(%s %q %s) = %v, want %v", entry.Address, entry.MacAddress, entry.Status, got, want[i])
+		}
+	}
+}
+
+func TestKnownHostsCallbackRemembersAccepted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	callback, err := knownHostsCallback(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A single answer: a second prompt would read EOF and reject the key
+	prev := stdin
+	stdin = bufio.NewReader(strings.NewReader("yes\n"))
+	t.Cleanup(func() { stdin = prev })
+
+	remote := &net.TCPAddr{IP: net.ParseIP("192.168.88.1"), Port: 22}
+	for attempt := 1; attempt <= 2; attempt++ {
+		if err := callback("192.168.88.1:22", remote, key); err != nil {
+			t.Fatalf("attempt %d: %v", attempt, err)
+		}
+	}
+	if rest, _ := stdin.ReadString('\n'); rest != "" {
+		t.Errorf("unread input %q", rest)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 1 {
+		t.Errorf("known_hosts has %d lines, want the host added once:\n%s", n, data)
+	}
+}
+
+func TestEnrichLeasesMalformedMAC(t *testing.T) {
+	*noVendorFlag = true
+	t.Cleanup(func() { *noVendorFlag = false })
+
+	leases := []routeros.DHCPLease{
+		{Address: "192.168.88.2", MacAddress: "AA:BB"},
+		{Address: "192.168.88.3", MacAddress: ""},
+		{Address: "192.168.88.4", MacAddress: "B8:27:EB:12:34:56"},
+	}
+	enrichLeases(context.Background(), leases)
+	for _, lease := range leases[:2] {
+		if lease.Error == "" || lease.Vendor != "" {
+			t.Errorf("lease %s with MAC %q: error %q, vendor %q; want an error and no vendor",
+				lease.Address, lease.MacAddress, lease.Error, lease.Vendor)
+		}
+	}
+	if leases[2].Error != "" {
+		t.Errorf("valid MAC got error %q", leases[2].Error)
+	}
+}
+
+func TestShellExecEcho(t *testing.T) {
+	const marker = "--routeros-tools-1--"
+	put := fmt.Sprintf(":put (%q . %q)", marker[:len(marker)/2], marker[len(marker)/2:])
+	cmd := "/system script print"
+	tests := []struct {
+		name   string
+		stream string
+		want   string
+	}{
+		{
+			"echo stripped",
+			"[admin@MikroTik] > " + cmd + "\r\n 0 name=backup\r\n[admin@MikroTik] > " + put + "\r\n" + marker + "\r\n",
+			" 0 name=backup\n",
+		},
+		{
+			// A script whose source ends with the command text is output
+			"output ending with the command",
+			"[admin@MikroTik] > " + cmd + "\r\nsource=" + cmd + "\r\n" + cmd + "\r\n[admin@MikroTik] > " + put + "\r\n" + marker + "\r\n",
+			"source=" + cmd + "\n" + cmd + "\n",
+		},
+		{
+			"no trailing newline",
+			"[admin@MikroTik] /ip dhcp-server> " + cmd + "\r\npartial[admin@MikroTik] /ip dhcp-server> " + put + "\r\n" + marker + "\r\n",
+			"partial\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sh := &routerShell{stdin: io.Discard, stdout: bufio.NewReader(strings.NewReader(tt.stream))}
+			got, err := sh.exec(cmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("exec = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}